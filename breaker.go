@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var errPeerUnavailable = errors.New("peer unavailable")
+
+// breakerTransport stops sending get requests to a peer after threshold
+// consecutive failures, so groupcache loads the affected keys locally instead
+// of waiting on a hung peer until it is removed from the pool. The peer is
+// tried again after backoff, which doubles with every further failure up to
+// maxBackoff. Only transport errors and gateway or unavailable responses
+// count as failures, other errors come from the peer's getter.
+type breakerTransport struct {
+	threshold  int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	failures   *prometheus.CounterVec
+	rejected   *prometheus.CounterVec
+	next       http.RoundTripper
+
+	mu    sync.Mutex
+	peers map[string]*breakerState
+}
+
+type breakerState struct {
+	failures  int
+	openUntil time.Time
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.next.RoundTrip(req)
+	}
+	peer := req.URL.Scheme + "://" + req.URL.Host
+
+	t.mu.Lock()
+	if t.peers == nil {
+		t.peers = map[string]*breakerState{}
+	}
+	state, ok := t.peers[peer]
+	if !ok {
+		state = &breakerState{}
+		t.peers[peer] = state
+	}
+	open, failures := time.Now().Before(state.openUntil), state.failures
+	t.mu.Unlock()
+	if open {
+		t.rejected.WithLabelValues(peer).Inc()
+		return nil, fmt.Errorf("%w: %s failed %d times in a row", errPeerUnavailable, peer, failures)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	failed := err != nil && req.Context().Err() == nil
+	if err == nil {
+		switch resp.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			failed = true
+		}
+	}
+
+	t.mu.Lock()
+	if failed {
+		state.failures++
+		if state.failures >= t.threshold {
+			backoff := t.maxBackoff
+			if shift := state.failures - t.threshold; shift < 32 && t.backoff<<shift < t.maxBackoff {
+				backoff = t.backoff << shift
+			}
+			state.openUntil = time.Now().Add(backoff)
+		}
+	} else {
+		state.failures = 0
+	}
+	t.mu.Unlock()
+	if failed {
+		t.failures.WithLabelValues(peer).Inc()
+	}
+	return resp, err
+}
+
+// prune forgets the state and the metrics of peers no longer in peers, so
+// they do not pile up as pods come and go.
+func (t *breakerTransport) prune(peers []string) {
+	keep := make(map[string]bool, len(peers))
+	for _, peer := range peers {
+		if u, err := url.Parse(peer); err == nil {
+			keep[u.Scheme+"://"+u.Host] = true
+		}
+	}
+	var gone []string
+	t.mu.Lock()
+	for peer := range t.peers {
+		if !keep[peer] {
+			delete(t.peers, peer)
+			gone = append(gone, peer)
+		}
+	}
+	t.mu.Unlock()
+	for _, peer := range gone {
+		t.failures.DeleteLabelValues(peer)
+		t.rejected.DeleteLabelValues(peer)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/databus23/k8sgroupcache/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestBreakerPrune(t *testing.T) {
+	breaker := &breakerTransport{
+		threshold:  1,
+		backoff:    time.Minute,
+		maxBackoff: time.Minute,
+		failures:   metrics.NewPeerFailuresCounter("test"),
+		rejected:   metrics.NewPeerRejectedCounter("test"),
+		next:       failingTransport{},
+	}
+	for _, peer := range []string{"http://10.0.0.1:8080", "http://10.0.0.2:8080"} {
+		for i := 0; i < 2; i++ {
+			req, _ := http.NewRequest("GET", peer+"/_groupcache/test/key", nil)
+			breaker.RoundTrip(req)
+		}
+	}
+	if n := testutil.CollectAndCount(breaker.failures); n != 2 {
+		t.Fatalf("got failures for %d peers, want 2", n)
+	}
+	if n := testutil.CollectAndCount(breaker.rejected); n != 2 {
+		t.Fatalf("got rejections for %d peers, want 2", n)
+	}
+
+	breaker.prune([]string{"http://10.0.0.1:8080", "http://10.0.0.3:8080"})
+
+	if _, ok := breaker.peers["http://10.0.0.2:8080"]; ok {
+		t.Error("state of removed peer was kept")
+	}
+	if _, ok := breaker.peers["http://10.0.0.1:8080"]; !ok {
+		t.Error("state of remaining peer was dropped")
+	}
+	if n := testutil.CollectAndCount(breaker.failures); n != 1 {
+		t.Errorf("got failures for %d peers, want 1", n)
+	}
+	if n := testutil.CollectAndCount(breaker.rejected); n != 1 {
+		t.Errorf("got rejections for %d peers, want 1", n)
+	}
+	if v := testutil.ToFloat64(breaker.failures.WithLabelValues("http://10.0.0.1:8080")); v != 1 {
+		t.Errorf("got %v failures for remaining peer, want 1", v)
+	}
+}
@@ -0,0 +1,136 @@
+// Package cache wires a groupcache peer pool, its peer discovery in
+// Kubernetes, a group and their metrics together.
+package cache
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/databus23/k8sgroupcache/k8spool"
+	"github.com/databus23/k8sgroupcache/metrics"
+	"github.com/mailgun/groupcache/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+type Options struct {
+	// Self is the URL of the local peer as built by k8spool.PeerURL.
+	Self string
+	// Pool configures the peer pool like groupcache's HTTP pool. BasePath
+	// defaults to /_groupcache/.
+	Pool groupcache.HTTPPoolOptions
+	// Peers configures the peer discovery. The pool is updated with the
+	// peers weighted by k8spool.WeightedPeers before OnWeightedUpdate and
+	// after OnUpdate are called, both are optional.
+	Peers k8spool.Config
+	// Discovery is used instead of discovering peers with Peers if set, e.g.
+	// a fake.Pool in tests. The pool is updated from its Updates channel.
+	Discovery k8spool.Pool
+
+	GroupName  string
+	CacheBytes int64
+	Getter     groupcache.Getter
+
+	// Registry the group and peer discovery collectors are registered with.
+	// A new one is created if nil.
+	Registry *prometheus.Registry
+	// Metrics labels the group metrics. Namespace defaults to the watched
+	// namespace.
+	Metrics metrics.CollectorOptions
+}
+
+type Cache struct {
+	Pool *Pool
+	// Peers is nil if Options.Discovery was set.
+	Peers     *k8spool.K8sPool
+	Group     *groupcache.Group
+	Registry  *prometheus.Registry
+	discovery k8spool.Pool
+}
+
+// New creates the pool, keeps its peers updated from Kubernetes until ctx is
+// done and creates the group. groupcache supports a single pool per process
+// and unique group names only, so New must not be called more than once.
+func New(ctx context.Context, opts Options) (*Cache, error) {
+	pool := newPool(opts.Self, opts.Pool)
+
+	onWeightedUpdate := opts.Peers.OnWeightedUpdate
+	opts.Peers.OnWeightedUpdate = func(weights map[string]int) {
+		pool.Set(k8spool.WeightedPeers(weights)...)
+		if onWeightedUpdate != nil {
+			onWeightedUpdate(weights)
+		}
+	}
+	var peers *k8spool.K8sPool
+	discovery := opts.Discovery
+	if discovery == nil {
+		var err error
+		peers, err = k8spool.NewWithContext(ctx, opts.Peers)
+		if err != nil {
+			if peers != nil {
+				peers.Close()
+			}
+			return nil, fmt.Errorf("Failed to start peer discovery: %w", err)
+		}
+		discovery = peers
+	} else {
+		updates := discovery.Updates()
+		go func() {
+			for peers := range updates {
+				pool.Set(peers...)
+			}
+		}()
+	}
+
+	group := groupcache.NewGroup(opts.GroupName, opts.CacheBytes, opts.Getter)
+
+	reg := opts.Registry
+	if reg == nil {
+		reg = prometheus.NewRegistry()
+	}
+	if opts.Metrics.Namespace == "" && peers != nil {
+		opts.Metrics.Namespace = peers.Namespace()
+	}
+	collectors := []prometheus.Collector{metrics.NewGroupCollector(group, opts.Metrics)}
+	if peers != nil {
+		collectors = append(collectors, metrics.NewPoolCollector(peers))
+	}
+	for _, c := range collectors {
+		if err := reg.Register(c); err != nil {
+			discovery.Close()
+			return nil, fmt.Errorf("Failed to register metrics: %w", err)
+		}
+	}
+
+	return &Cache{
+		Pool:      pool,
+		Peers:     peers,
+		Group:     group,
+		Registry:  reg,
+		discovery: discovery,
+	}, nil
+}
+
+// WhichPeer returns the URL of the peer owning key according to the pool's
+// consistent hash ring, which is Self if the key is loaded locally or no
+// peers are known yet. The ring only depends on the peer set, the hash
+// function and the number of replicas, not on the order peers were set in,
+// so with the same options all peers agree on the owner.
+func (c *Cache) WhichPeer(key string) string {
+	return c.Pool.Owner(key)
+}
+
+// Handler returns a handler serving peer requests under the pool's base
+// path and the metrics of Registry under /metrics.
+func (c *Cache) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(c.Pool.opts.BasePath, c.Pool)
+	mux.Handle("/metrics", promhttp.HandlerFor(c.Registry, promhttp.HandlerOpts{}))
+	return mux
+}
+
+// Close stops the peer discovery.
+func (c *Cache) Close() {
+	c.discovery.Close()
+}
@@ -0,0 +1,196 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/databus23/k8sgroupcache/getter"
+	"github.com/databus23/k8sgroupcache/k8spool"
+	"github.com/databus23/k8sgroupcache/k8spool/fake"
+	"github.com/golang/protobuf/proto"
+	"github.com/mailgun/groupcache/v2"
+	pb "github.com/mailgun/groupcache/v2/groupcachepb"
+)
+
+const testSelf = "http://self:8080"
+
+// groupcache supports a single pool per process, so the tests share a cache.
+var (
+	testOnce  sync.Once
+	testCache *Cache
+	testPeers *fake.Pool
+	testErr   error
+)
+
+func newTestCache(t *testing.T) *Cache {
+	t.Helper()
+	testOnce.Do(func() {
+		origin := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			fmt.Fprintf(rw, "value of %s", strings.TrimPrefix(req.URL.Path, "/"))
+		}))
+		testPeers = fake.New(testSelf)
+		testCache, testErr = New(context.Background(), Options{
+			Self:       testSelf,
+			Discovery:  testPeers,
+			GroupName:  "test",
+			CacheBytes: 1 << 20,
+			Getter:     getter.NewHTTP(origin.URL),
+		})
+	})
+	if testErr != nil {
+		t.Fatalf("Failed to create cache: %s", testErr)
+	}
+	return testCache
+}
+
+func TestHandlerGet(t *testing.T) {
+	c := newTestCache(t)
+	server := httptest.NewServer(c.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/_groupcache/test/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %s", resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out pb.GetResponse
+	if err := proto.Unmarshal(body, &out); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(out.GetValue()); got != "value of foo" {
+		t.Errorf("got value %q, want %q", got, "value of foo")
+	}
+	if loads := c.Group.Stats.LocalLoads.Get(); loads != 1 {
+		t.Errorf("got %d local loads, want 1", loads)
+	}
+
+	resp, err = http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("got metrics status %s", resp.Status)
+	}
+}
+
+func TestWhichPeer(t *testing.T) {
+	c := newTestCache(t)
+	const other = "http://other:8080"
+	testPeers.SetPeers(testSelf, other)
+	defer testPeers.SetPeers(testSelf)
+	deadline := time.Now().Add(time.Second)
+	for len(c.Pool.GetAll()) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the peers to be set")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	var shares []int
+	for _, weights := range []map[string]int{
+		{testSelf: 1, other: 1},
+		{testSelf: 1, other: 3},
+	} {
+		c.Pool.Set(k8spool.WeightedPeers(weights)...)
+		owners := map[string]int{}
+		for i := 0; i < 1000; i++ {
+			key := fmt.Sprintf("key-%d", i)
+			owner := c.WhichPeer(key)
+			_, remote := c.Pool.PickPeer(key)
+			if remote != (owner == other) {
+				t.Errorf("%v: got owner %s for %s, picked remote peer: %t", weights, owner, key, remote)
+			}
+			if again := c.WhichPeer(key); again != owner {
+				t.Errorf("%v: got owner %s for %s, then %s", weights, owner, key, again)
+			}
+			owners[owner]++
+		}
+		if len(owners) != 2 || owners[testSelf] == 0 || owners[other] == 0 {
+			t.Errorf("%v: keys not spread over both peers: %v", weights, owners)
+		}
+		shares = append(shares, owners[other])
+	}
+	if shares[1] <= shares[0] {
+		t.Errorf("weighting %s did not increase its keys: %d before, %d after", other, shares[0], shares[1])
+	}
+}
+
+func TestWeightedSelfLoadsLocally(t *testing.T) {
+	c := newTestCache(t)
+	const other = "http://other:8080"
+	c.Pool.Set(k8spool.WeightedPeers(map[string]int{testSelf: 3, other: 1})...)
+	defer c.Pool.Set(testSelf)
+
+	peerErrors, localLoads := c.Group.Stats.PeerErrors.Get(), c.Group.Stats.LocalLoads.Get()
+	var loaded int64
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("weighted-%d", i)
+		if c.WhichPeer(key) != testSelf {
+			continue
+		}
+		if _, remote := c.Pool.PickPeer(key); remote {
+			t.Errorf("picked remote peer for %s owned by self", key)
+		}
+		var value string
+		if err := c.Group.Get(context.Background(), key, groupcache.StringSink(&value)); err != nil {
+			t.Fatal(err)
+		}
+		loaded++
+	}
+	if loaded == 0 {
+		t.Fatal("no key owned by self")
+	}
+	if n := c.Group.Stats.PeerErrors.Get() - peerErrors; n != 0 {
+		t.Errorf("got %d peer errors", n)
+	}
+	if n := c.Group.Stats.LocalLoads.Get() - localLoads; n != loaded {
+		t.Errorf("got %d local loads, want %d", n, loaded)
+	}
+	for _, peer := range c.Pool.GetAll() {
+		if peer.GetURL() != other+"/_groupcache/" {
+			t.Errorf("got peer %s, want only %s", peer.GetURL(), other)
+		}
+	}
+}
+
+func TestHandlerDelete(t *testing.T) {
+	c := newTestCache(t)
+	server := httptest.NewServer(c.Handler())
+	defer server.Close()
+
+	var value string
+	if err := c.Group.Get(context.Background(), "deleted", groupcache.StringSink(&value)); err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodDelete, server.URL+"/_groupcache/test/deleted", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %s", resp.Status)
+	}
+	loads := c.Group.Stats.LocalLoads.Get()
+	if err := c.Group.Get(context.Background(), "deleted", groupcache.StringSink(&value)); err != nil {
+		t.Fatal(err)
+	}
+	if n := c.Group.Stats.LocalLoads.Get() - loads; n != 1 {
+		t.Errorf("got %d local loads after removal, want 1", n)
+	}
+}
@@ -0,0 +1,237 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/databus23/k8sgroupcache/k8spool"
+	"github.com/golang/protobuf/proto"
+	"github.com/mailgun/groupcache/v2"
+	"github.com/mailgun/groupcache/v2/consistenthash"
+	pb "github.com/mailgun/groupcache/v2/groupcachepb"
+)
+
+// Pool is a groupcache peer picker speaking the protocol of groupcache's
+// HTTPPool. Unlike HTTPPool it resolves the points of the hash ring to the
+// peer they stand for with k8spool.PeerOf, so the repetitions created by
+// k8spool.WeightedPeers give a peer a larger share of the keys and keys owned
+// by a repetition of the local peer are loaded locally.
+type Pool struct {
+	self string
+	opts groupcache.HTTPPoolOptions
+
+	mu    sync.Mutex
+	ring  *consistenthash.Map
+	peers map[string]*httpPeer
+}
+
+var _ groupcache.PeerPicker = (*Pool)(nil)
+
+// newPool creates the pool and registers it with groupcache, which supports
+// a single peer picker per process.
+func newPool(self string, opts groupcache.HTTPPoolOptions) *Pool {
+	if opts.BasePath == "" {
+		opts.BasePath = "/_groupcache/"
+	}
+	if opts.Replicas == 0 {
+		opts.Replicas = 50
+	}
+	p := &Pool{self: self, opts: opts, ring: consistenthash.New(opts.Replicas, opts.HashFn)}
+	groupcache.RegisterPeerPicker(func() groupcache.PeerPicker { return p })
+	return p
+}
+
+// Set replaces the peers, which may contain repetitions created by
+// k8spool.WeightedPeers.
+func (p *Pool) Set(peers ...string) {
+	ring := consistenthash.New(p.opts.Replicas, p.opts.HashFn)
+	ring.Add(peers...)
+	getters := make(map[string]*httpPeer, len(peers))
+	for _, peer := range peers {
+		peer = k8spool.PeerOf(peer)
+		if peer == p.self || getters[peer] != nil {
+			continue
+		}
+		getters[peer] = &httpPeer{transport: p.opts.Transport, baseURL: peer + p.opts.BasePath}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ring, p.peers = ring, getters
+}
+
+// Owner returns the peer owning key, which is self if no peers are set.
+func (p *Pool) Owner(key string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.owner(key)
+}
+
+func (p *Pool) owner(key string) string {
+	if p.ring.IsEmpty() {
+		return p.self
+	}
+	return k8spool.PeerOf(p.ring.Get(key))
+}
+
+func (p *Pool) PickPeer(key string) (groupcache.ProtoGetter, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	peer, ok := p.peers[p.owner(key)]
+	return peer, ok
+}
+
+// GetAll returns all peers but self.
+func (p *Pool) GetAll() []groupcache.ProtoGetter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	all := make([]groupcache.ProtoGetter, 0, len(p.peers))
+	for _, peer := range p.peers {
+		all = append(all, peer)
+	}
+	return all
+}
+
+type peerRequestKey struct{}
+
+// ServeHTTP serves the requests of peers under BasePath. groupcache only
+// offers removing and setting keys including their peers, so removals and
+// sets are applied with a context keeping them from being passed on. Unlike
+// with HTTPPool, a set is therefore only stored if the key is owned locally.
+func (p *Pool) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	path, ok := strings.CutPrefix(req.URL.Path, p.opts.BasePath)
+	groupName, key, found := strings.Cut(path, "/")
+	if !ok || !found {
+		http.Error(rw, "bad request", http.StatusBadRequest)
+		return
+	}
+	group := groupcache.GetGroup(groupName)
+	if group == nil {
+		http.Error(rw, "no such group: "+groupName, http.StatusNotFound)
+		return
+	}
+	ctx := req.Context()
+	if p.opts.Context != nil {
+		ctx = p.opts.Context(req)
+	}
+	group.Stats.ServerRequests.Add(1)
+
+	switch req.Method {
+	case http.MethodDelete:
+		if err := group.Remove(context.WithValue(ctx, peerRequestKey{}, true), key); err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	case http.MethodPut:
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var in pb.SetRequest
+		if err := proto.Unmarshal(body, &in); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var expire time.Time
+		if in.GetExpire() != 0 {
+			expire = time.Unix(0, in.GetExpire())
+		}
+		if err := group.Set(context.WithValue(ctx, peerRequestKey{}, true), in.GetKey(), in.GetValue(), expire, false); err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	var view groupcache.ByteView
+	if err := group.Get(ctx, key, groupcache.ByteViewSink(&view)); err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var expire int64
+	if !view.Expire().IsZero() {
+		expire = view.Expire().UnixNano()
+	}
+	body, err := proto.Marshal(&pb.GetResponse{Value: view.ByteSlice(), Expire: &expire})
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/x-protobuf")
+	rw.Write(body)
+}
+
+// httpPeer requests keys from a peer like groupcache's HTTPPool.
+type httpPeer struct {
+	transport func(context.Context) http.RoundTripper
+	baseURL   string
+}
+
+func (h *httpPeer) GetURL() string {
+	return h.baseURL
+}
+
+func (h *httpPeer) do(ctx context.Context, method string, group, key string, body []byte) ([]byte, error) {
+	u := h.baseURL + url.QueryEscape(group) + "/" + url.QueryEscape(key)
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport
+	if h.transport != nil {
+		transport = h.transport(ctx)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		return nil, fmt.Errorf("server returned: %s, %s", resp.Status, msg)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read response body: %w", err)
+	}
+	return b, nil
+}
+
+func (h *httpPeer) Get(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
+	b, err := h.do(ctx, http.MethodGet, in.GetGroup(), in.GetKey(), nil)
+	if err != nil {
+		return err
+	}
+	if err := proto.Unmarshal(b, out); err != nil {
+		return fmt.Errorf("Failed to decode response body: %w", err)
+	}
+	return nil
+}
+
+// Remove and Set do nothing when serving a peer's request, which the peer
+// passes on to the other peers itself.
+func (h *httpPeer) Remove(ctx context.Context, in *pb.GetRequest) error {
+	if ctx.Value(peerRequestKey{}) != nil {
+		return nil
+	}
+	_, err := h.do(ctx, http.MethodDelete, in.GetGroup(), in.GetKey(), nil)
+	return err
+}
+
+func (h *httpPeer) Set(ctx context.Context, in *pb.SetRequest) error {
+	if ctx.Value(peerRequestKey{}) != nil {
+		return nil
+	}
+	body, err := proto.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("Failed to encode set request: %w", err)
+	}
+	_, err = h.do(ctx, http.MethodPut, in.GetGroup(), in.GetKey(), body)
+	return err
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/databus23/k8sgroupcache/getter"
+	"github.com/mailgun/groupcache/v2"
+)
+
+// compressHandler gzips responses of next of at least minBytes if the client
+// accepts it. The response is buffered to decide on the size. Responses
+// already carrying a Content-Encoding are passed through.
+//
+// Go's http.Transport requests gzip and decompresses transparently, so this
+// also works for groupcache peer requests without a client side change.
+func compressHandler(next http.Handler, minBytes int) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodHead || !acceptsGzip(req) {
+			next.ServeHTTP(rw, req)
+			return
+		}
+		rec := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+		next.ServeHTTP(rec, req)
+
+		header := rw.Header()
+		for k, v := range rec.header {
+			header[k] = v
+		}
+		header.Add("Vary", "Accept-Encoding")
+		if rec.status == http.StatusNotModified || rec.status == http.StatusNoContent {
+			// These responses have no body to compress.
+			rw.WriteHeader(rec.status)
+			return
+		}
+		body := rec.body.Bytes()
+		if rec.body.Len() >= minBytes && header.Get("Content-Encoding") == "" {
+			var buf bytes.Buffer
+			zw := gzip.NewWriter(&buf)
+			if _, err := zw.Write(body); err == nil && zw.Close() == nil {
+				body = buf.Bytes()
+				header.Set("Content-Encoding", "gzip")
+				// The compressed representation differs, so only a weak
+				// validator may be kept.
+				if tag := header.Get("ETag"); tag != "" && !strings.HasPrefix(tag, "W/") {
+					header.Set("ETag", "W/"+tag)
+				}
+			}
+		}
+		header.Set("Content-Length", strconv.Itoa(len(body)))
+		rw.WriteHeader(rec.status)
+		rw.Write(body)
+	})
+}
+
+func acceptsGzip(req *http.Request) bool {
+	for _, enc := range strings.Split(req.Header.Get("Accept-Encoding"), ",") {
+		enc, params, _ := strings.Cut(strings.TrimSpace(enc), ";")
+		if strings.EqualFold(enc, "gzip") && strings.TrimSpace(params) != "q=0" {
+			return true
+		}
+	}
+	return false
+}
+
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *bufferedResponse) Header() http.Header { return r.header }
+
+func (r *bufferedResponse) WriteHeader(status int) { r.status = status }
+
+func (r *bufferedResponse) Write(b []byte) (int, error) { return r.body.Write(b) }
+
+// getValue loads key from group, decompressing the value if the group stores
+// gzipped values (--cache-gzip).
+func getValue(ctx context.Context, group *groupcache.Group, key string, gzipped bool) (groupcache.ByteView, error) {
+	var value groupcache.ByteView
+	if err := tracedGet(ctx, group, key, groupcache.ByteViewSink(&value)); err != nil || !gzipped {
+		return value, err
+	}
+	b, err := getter.Gunzip(value)
+	if err != nil {
+		return value, fmt.Errorf("Failed to decompress value: %w", err)
+	}
+	var plain groupcache.ByteView
+	err = groupcache.ByteViewSink(&plain).SetBytes(b, value.Expire())
+	return plain, err
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/databus23/k8sgroupcache/getter"
+)
+
+// cleanDisk cleans the disk cache right away and then every interval until
+// ctx is done.
+func cleanDisk(ctx context.Context, disk *getter.Disk, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := disk.Clean(); err != nil {
+			log.Printf("Failed to clean disk cache: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package getter
+
+import (
+	"context"
+
+	"github.com/mailgun/groupcache/v2"
+)
+
+// WithContext wraps g so that loads are aborted with the context error once
+// ctx is done. The value is buffered and only handed to groupcache after g
+// returned successfully with ctx still active, so a cancelled load never
+// stores a partial value.
+//
+// groupcache dedups concurrent loads of the same key, so the ctx seen by g is
+// the one of the request that started the load. Cancelling it aborts the load
+// for all callers waiting on it, not just for the caller that went away.
+func WithContext(g groupcache.Getter) groupcache.Getter {
+	return groupcache.GetterFunc(func(ctx context.Context, key string, dest groupcache.Sink) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var value groupcache.ByteView
+		done := make(chan error, 1)
+		go func() {
+			done <- g.Get(ctx, key, groupcache.ByteViewSink(&value))
+		}()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-done:
+			if err != nil {
+				return err
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return dest.SetBytes(value.ByteSlice(), value.Expire())
+	})
+}
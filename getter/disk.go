@@ -0,0 +1,204 @@
+package getter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mailgun/groupcache/v2"
+)
+
+// Disk is a groupcache.Getter keeping the values loaded by getter in files
+// below dir, so they survive restarts. A file is used until its value
+// expires or, if ttl is positive, until it is older than ttl. Expired files
+// are removed when they are read again or by Clean.
+type Disk struct {
+	// MaxBytes limits the size of the files kept by Clean if positive.
+	MaxBytes int64
+
+	getter groupcache.Getter
+	dir    string
+	ttl    time.Duration
+	hits   int64
+	misses int64
+}
+
+func NewDisk(getter groupcache.Getter, dir string, ttl time.Duration) (*Disk, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("Failed to create disk cache dir: %w", err)
+	}
+	return &Disk{getter: getter, dir: dir, ttl: ttl}, nil
+}
+
+func (d *Disk) Get(ctx context.Context, key string, dest groupcache.Sink) error {
+	path := d.path(key)
+	if value, expire, ok := d.read(path); ok {
+		atomic.AddInt64(&d.hits, 1)
+		return dest.SetBytes(value, expire)
+	}
+	atomic.AddInt64(&d.misses, 1)
+
+	var value groupcache.ByteView
+	if err := d.getter.Get(ctx, key, groupcache.ByteViewSink(&value)); err != nil {
+		return err
+	}
+	// Failing to write only costs another load from the origin later.
+	d.write(path, value)
+	return dest.SetBytes(value.ByteSlice(), value.Expire())
+}
+
+// Hits returns the number of loads served from disk.
+func (d *Disk) Hits() int64 {
+	return atomic.LoadInt64(&d.hits)
+}
+
+// Misses returns the number of loads passed on to the wrapped getter.
+func (d *Disk) Misses() int64 {
+	return atomic.LoadInt64(&d.misses)
+}
+
+func (d *Disk) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(d.dir, name[:2], name)
+}
+
+// Files hold the expiry in unix nanoseconds, zero meaning never, followed by
+// the value.
+func (d *Disk) read(path string) ([]byte, time.Time, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+	if d.ttl > 0 && time.Since(info.ModTime()) > d.ttl {
+		os.Remove(path)
+		return nil, time.Time{}, false
+	}
+	b, err := os.ReadFile(path)
+	if err != nil || len(b) < 8 {
+		return nil, time.Time{}, false
+	}
+	expire := fileExpiry(b)
+	if !expire.IsZero() && time.Now().After(expire) {
+		os.Remove(path)
+		return nil, time.Time{}, false
+	}
+	return b[8:], expire, true
+}
+
+func fileExpiry(header []byte) time.Time {
+	if nanos := int64(binary.BigEndian.Uint64(header)); nanos != 0 {
+		return time.Unix(0, nanos)
+	}
+	return time.Time{}
+}
+
+// Clean removes expired files, files with a broken header and temporary files
+// left behind by a crash. If MaxBytes is positive it then removes the oldest
+// files until the rest takes at most MaxBytes.
+func (d *Disk) Clean() error {
+	type file struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var files []file
+	var total int64
+	now := time.Now()
+	err := filepath.WalkDir(d.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			// Removed since listing the directory.
+			return nil
+		}
+		if strings.HasPrefix(entry.Name(), ".tmp-") {
+			if now.Sub(info.ModTime()) > time.Minute {
+				os.Remove(path)
+			}
+			return nil
+		}
+		if d.ttl > 0 && now.Sub(info.ModTime()) > d.ttl || d.expired(path, now) {
+			os.Remove(path)
+			return nil
+		}
+		files = append(files, file{path: path, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to clean disk cache: %w", err)
+	}
+	if d.MaxBytes <= 0 || total <= d.MaxBytes {
+		return nil
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, f := range files {
+		if total <= d.MaxBytes {
+			break
+		}
+		if err := os.Remove(f.path); err == nil || errors.Is(err, fs.ErrNotExist) {
+			total -= f.size
+		}
+	}
+	return nil
+}
+
+// expired reports whether the file at path holds an expired value or has a
+// broken header.
+func (d *Disk) expired(path string, now time.Time) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	var header [8]byte
+	if _, err := io.ReadFull(f, header[:]); err != nil {
+		return true
+	}
+	expire := fileExpiry(header[:])
+	return !expire.IsZero() && now.After(expire)
+}
+
+// write stores value at path via a temporary file, so readers never see a
+// partial file.
+func (d *Disk) write(path string, value groupcache.ByteView) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	var header [8]byte
+	if !value.Expire().IsZero() {
+		binary.BigEndian.PutUint64(header[:], uint64(value.Expire().UnixNano()))
+	}
+	_, err = f.Write(header[:])
+	if err == nil {
+		_, err = value.WriteTo(f)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
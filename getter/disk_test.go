@@ -0,0 +1,166 @@
+package getter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mailgun/groupcache/v2"
+)
+
+// countingGetter returns "value of <key>" expiring at expire and counts its
+// loads.
+type countingGetter struct {
+	loads  int32
+	expire time.Time
+}
+
+func (g *countingGetter) Get(ctx context.Context, key string, dest groupcache.Sink) error {
+	atomic.AddInt32(&g.loads, 1)
+	return dest.SetString("value of "+key, g.expire)
+}
+
+func getString(t *testing.T, getter groupcache.Getter, key string) groupcache.ByteView {
+	t.Helper()
+	var value groupcache.ByteView
+	if err := getter.Get(context.Background(), key, groupcache.ByteViewSink(&value)); err != nil {
+		t.Fatalf("%s: %s", key, err)
+	}
+	if want := "value of " + key; value.String() != want {
+		t.Errorf("%s: got value %q, want %q", key, value.String(), want)
+	}
+	return value
+}
+
+func TestDiskHitAndMiss(t *testing.T) {
+	origin := &countingGetter{expire: time.Now().Add(time.Hour).Round(0)}
+	disk, err := NewDisk(origin, t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	getString(t, disk, "key")
+	value := getString(t, disk, "key")
+	if !value.Expire().Equal(origin.expire) {
+		t.Errorf("got expiry %s from disk, want %s", value.Expire(), origin.expire)
+	}
+	if origin.loads != 1 || disk.Hits() != 1 || disk.Misses() != 1 {
+		t.Errorf("got %d loads, %d hits and %d misses, want 1 each", origin.loads, disk.Hits(), disk.Misses())
+	}
+
+	// A new Disk on the same directory, e.g. after a restart, uses the file.
+	restarted, err := NewDisk(origin, disk.dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	getString(t, restarted, "key")
+	if origin.loads != 1 {
+		t.Errorf("got %d loads after restart, want 1", origin.loads)
+	}
+	tmp, _ := filepath.Glob(filepath.Join(disk.dir, "*", ".tmp-*"))
+	if len(tmp) != 0 {
+		t.Errorf("temporary files left behind: %v", tmp)
+	}
+}
+
+func TestDiskExpiry(t *testing.T) {
+	origin := &countingGetter{expire: time.Now().Add(-time.Second)}
+	disk, err := NewDisk(origin, t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	getString(t, disk, "expired")
+	getString(t, disk, "expired")
+	if origin.loads != 2 {
+		t.Errorf("got %d loads of an expired value, want 2", origin.loads)
+	}
+
+	origin = &countingGetter{}
+	disk, err = NewDisk(origin, t.TempDir(), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	getString(t, disk, "old")
+	old := time.Now().Add(-2 * time.Minute)
+	if err := os.Chtimes(disk.path("old"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	getString(t, disk, "old")
+	if origin.loads != 2 {
+		t.Errorf("got %d loads of a value older than the TTL, want 2", origin.loads)
+	}
+}
+
+func TestDiskCorruptHeader(t *testing.T) {
+	origin := &countingGetter{}
+	disk, err := NewDisk(origin, t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := disk.path("corrupt")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte{1, 2, 3}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	getString(t, disk, "corrupt")
+	getString(t, disk, "corrupt")
+	if origin.loads != 1 || disk.Hits() != 1 {
+		t.Errorf("got %d loads and %d hits, want the corrupt file replaced", origin.loads, disk.Hits())
+	}
+}
+
+func TestDiskClean(t *testing.T) {
+	origin := &countingGetter{}
+	disk, err := NewDisk(origin, t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, key := range []string{"a", "b", "c"} {
+		getString(t, disk, key)
+		// Older keys were written earlier.
+		mod := time.Now().Add(time.Duration(i-3) * time.Minute)
+		if err := os.Chtimes(disk.path(key), mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	origin.expire = time.Now().Add(-time.Second)
+	getString(t, disk, "expired")
+	broken := disk.path("broken")
+	os.MkdirAll(filepath.Dir(broken), 0o755)
+	os.WriteFile(broken, []byte{1}, 0o644)
+	leftover := filepath.Join(filepath.Dir(disk.path("a")), ".tmp-leftover")
+	os.WriteFile(leftover, []byte("partial"), 0o644)
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(leftover, old, old)
+
+	size, err := os.Stat(disk.path("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	disk.MaxBytes = 2 * size.Size()
+	if err := disk.Clean(); err != nil {
+		t.Fatal(err)
+	}
+
+	var left []string
+	filepath.WalkDir(disk.dir, func(path string, entry os.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			left = append(left, path)
+		}
+		return nil
+	})
+	want := []string{disk.path("b"), disk.path("c")}
+	if len(left) != len(want) {
+		t.Fatalf("got files %v, want %v", left, want)
+	}
+	for _, path := range want {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("newest file %s removed: %s", strings.TrimPrefix(path, disk.dir), err)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package getter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"sync/atomic"
+
+	"github.com/mailgun/groupcache/v2"
+)
+
+// Gzipped is a groupcache.Getter storing the values of getter gzip
+// compressed, trading CPU for cache capacity. Everything reading the group
+// has to decompress the values with Gunzip, or serve them as is with
+// Content-Encoding gzip.
+type Gzipped struct {
+	getter     groupcache.Getter
+	raw        int64
+	compressed int64
+}
+
+func NewGzipped(getter groupcache.Getter) *Gzipped {
+	return &Gzipped{getter: getter}
+}
+
+func (g *Gzipped) Get(ctx context.Context, key string, dest groupcache.Sink) error {
+	var value groupcache.ByteView
+	if err := g.getter.Get(ctx, key, groupcache.ByteViewSink(&value)); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := value.WriteTo(zw); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	atomic.AddInt64(&g.raw, int64(value.Len()))
+	atomic.AddInt64(&g.compressed, int64(buf.Len()))
+	return dest.SetBytes(buf.Bytes(), value.Expire())
+}
+
+// Ratio returns the compressed size of all values loaded so far relative to
+// their uncompressed size, or 0 if nothing was loaded yet.
+func (g *Gzipped) Ratio() float64 {
+	raw := atomic.LoadInt64(&g.raw)
+	if raw == 0 {
+		return 0
+	}
+	return float64(atomic.LoadInt64(&g.compressed)) / float64(raw)
+}
+
+// Gunzip returns the decompressed bytes of a value stored by Gzipped.
+func Gunzip(value groupcache.ByteView) ([]byte, error) {
+	zr, err := gzip.NewReader(value.Reader())
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
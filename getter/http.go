@@ -0,0 +1,92 @@
+package getter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mailgun/groupcache/v2"
+)
+
+// HTTP is a groupcache.Getter that loads values from an upstream HTTP origin.
+// The cache key is used as the request path relative to the origin URL.
+type HTTP struct {
+	URL    string
+	Client *http.Client
+	// DefaultTTL is used for responses without Cache-Control max-age or
+	// Expires header. Zero means such values never expire.
+	DefaultTTL time.Duration
+}
+
+func NewHTTP(originURL string) *HTTP {
+	return &HTTP{URL: originURL, Client: http.DefaultClient}
+}
+
+func (h *HTTP) Get(ctx context.Context, key string, dest groupcache.Sink) error {
+	u := strings.TrimSuffix(h.URL, "/") + "/" + strings.TrimPrefix((&url.URL{Path: key}).EscapedPath(), "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return fmt.Errorf("Failed to create origin request: %w", err)
+	}
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Failed to fetch %s from origin: %w", key, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		io.Copy(io.Discard, resp.Body)
+		return fmt.Errorf("origin returned %s for %s", resp.Status, key)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("Failed to read origin response for %s: %w", key, err)
+	}
+	date := responseDate(resp.Header, time.Now())
+	expire := expiry(resp.Header, date)
+	if expire.IsZero() && h.DefaultTTL > 0 {
+		expire = date.Add(h.DefaultTTL)
+	}
+	// The absolute expiry is stored with the value and handed to peers
+	// loading it from us, so all peers expire the value at the same time.
+	return dest.SetBytes(body, expire)
+}
+
+// responseDate returns the origin's Date header, or now if it is missing or
+// invalid. Relative lifetimes are based on it instead of the local clock, so
+// every peer loading the same response computes the same absolute expiry.
+func responseDate(header http.Header, now time.Time) time.Time {
+	if t, err := http.ParseTime(header.Get("Date")); err == nil {
+		return t
+	}
+	return now
+}
+
+// expiry derives the absolute expiry of a response from its Cache-Control
+// max-age directive relative to date or, failing that, its Expires header.
+// A zero time means the value never expires.
+func expiry(header http.Header, date time.Time) time.Time {
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		if !strings.HasPrefix(directive, "max-age=") {
+			continue
+		}
+		if secs, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age=")); err == nil {
+			return date.Add(time.Duration(secs) * time.Second)
+		}
+	}
+	if e := header.Get("Expires"); e != "" {
+		if t, err := http.ParseTime(e); err == nil {
+			return t
+		}
+		// Invalid Expires values mean "already expired" (RFC 7234, 5.3)
+		return date
+	}
+	return time.Time{}
+}
@@ -0,0 +1,61 @@
+package getter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mailgun/groupcache/v2"
+)
+
+func TestHTTPExpiry(t *testing.T) {
+	// An origin whose clock is behind, as seen from the peers.
+	date := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+	origin := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Date", date.Format(http.TimeFormat))
+		switch req.URL.Path {
+		case "/max-age":
+			rw.Header().Set("Cache-Control", "public, max-age=300")
+		case "/expires":
+			rw.Header().Set("Expires", date.Add(time.Hour).Format(http.TimeFormat))
+		case "/invalid-expires":
+			rw.Header().Set("Expires", "0")
+		}
+		rw.Write([]byte("value"))
+	}))
+	defer origin.Close()
+
+	tests := []struct {
+		key        string
+		defaultTTL time.Duration
+		want       time.Time
+	}{
+		{key: "max-age", want: date.Add(300 * time.Second)},
+		{key: "max-age", defaultTTL: time.Hour, want: date.Add(300 * time.Second)},
+		{key: "expires", want: date.Add(time.Hour)},
+		{key: "invalid-expires", want: date},
+		{key: "none", want: time.Time{}},
+		{key: "none", defaultTTL: 10 * time.Second, want: date.Add(10 * time.Second)},
+	}
+	for _, tt := range tests {
+		// Each peer loading the value from the origin computes the same
+		// absolute expiry, regardless of when it loads it.
+		for peer := 0; peer < 2; peer++ {
+			getter := NewHTTP(origin.URL)
+			getter.DefaultTTL = tt.defaultTTL
+			var value groupcache.ByteView
+			if err := getter.Get(context.Background(), tt.key, groupcache.ByteViewSink(&value)); err != nil {
+				t.Fatalf("%s: %s", tt.key, err)
+			}
+			if value.String() != "value" {
+				t.Errorf("%s: got value %q", tt.key, value.String())
+			}
+			if !value.Expire().Equal(tt.want) {
+				t.Errorf("%s (default TTL %s, peer %d): got expiry %s, want %s", tt.key, tt.defaultTTL, peer, value.Expire(), tt.want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
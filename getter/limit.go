@@ -0,0 +1,41 @@
+package getter
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/mailgun/groupcache/v2"
+)
+
+// Limited is a groupcache.Getter allowing at most a fixed number of
+// concurrent loads. groupcache only dedups loads of the same key, so this
+// protects the origin when many distinct keys miss at once. Loads over the
+// limit wait for a free slot or until their context is done.
+type Limited struct {
+	getter   groupcache.Getter
+	sem      chan struct{}
+	inFlight int64
+}
+
+func NewLimited(getter groupcache.Getter, max int) *Limited {
+	return &Limited{getter: getter, sem: make(chan struct{}, max)}
+}
+
+func (l *Limited) Get(ctx context.Context, key string, dest groupcache.Sink) error {
+	select {
+	case l.sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	atomic.AddInt64(&l.inFlight, 1)
+	defer func() {
+		atomic.AddInt64(&l.inFlight, -1)
+		<-l.sem
+	}()
+	return l.getter.Get(ctx, key, dest)
+}
+
+// InFlight returns the number of loads currently running.
+func (l *Limited) InFlight() int {
+	return int(atomic.LoadInt64(&l.inFlight))
+}
@@ -0,0 +1,26 @@
+package getter
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/mailgun/groupcache/v2"
+)
+
+// ProtoFunc loads the message for key and the time it expires, zero meaning
+// never.
+type ProtoFunc func(ctx context.Context, key string) (proto.Message, time.Time, error)
+
+// Proto returns a groupcache.Getter storing the messages loaded by fn in
+// their binary wire format, so they can be read back with
+// groupcache.ProtoSink or served as is.
+func Proto(fn ProtoFunc) groupcache.Getter {
+	return groupcache.GetterFunc(func(ctx context.Context, key string, dest groupcache.Sink) error {
+		msg, expire, err := fn(ctx, key)
+		if err != nil {
+			return err
+		}
+		return dest.SetProto(msg, expire)
+	})
+}
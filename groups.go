@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/mailgun/groupcache/v2"
+)
+
+// groupRegistry keeps track of the groups created by the server together
+// with their configured size, which groupcache does not expose.
+type groupRegistry struct {
+	mu     sync.Mutex
+	groups map[string]int64
+}
+
+// Register records a group created with the given size.
+func (r *groupRegistry) Register(name string, cacheBytes int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.groups == nil {
+		r.groups = map[string]int64{}
+	}
+	r.groups[name] = cacheBytes
+}
+
+func (r *groupRegistry) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	type groupInfo struct {
+		Name           string `json:"name"`
+		CacheSizeBytes int64  `json:"cache_size_bytes"`
+		UsedBytes      int64  `json:"used_bytes"`
+	}
+	r.mu.Lock()
+	groups := make([]groupInfo, 0, len(r.groups))
+	for name, size := range r.groups {
+		info := groupInfo{Name: name, CacheSizeBytes: size}
+		if g := groupcache.GetGroup(name); g != nil {
+			info.UsedBytes = g.CacheStats(groupcache.MainCache).Bytes + g.CacheStats(groupcache.HotCache).Bytes
+		}
+		groups = append(groups, info)
+	}
+	r.mu.Unlock()
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(groups)
+}
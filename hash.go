@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/mailgun/groupcache/v2/consistenthash"
+)
+
+// parseHashFn returns the consistent hash function for name. The default
+// fnv1 is returned as nil, which makes groupcache use its built in one.
+// All peers must use the same function, otherwise they disagree on the key
+// owners.
+func parseHashFn(name string) (consistenthash.Hash, error) {
+	switch name {
+	case "", "fnv1":
+		return nil, nil
+	case "fnv1a":
+		return func(data []byte) uint64 {
+			h := fnv.New64a()
+			h.Write(data)
+			return h.Sum64()
+		}, nil
+	case "crc32":
+		return func(data []byte) uint64 { return uint64(crc32.ChecksumIEEE(data)) }, nil
+	case "xxhash":
+		return xxhash.Sum64, nil
+	default:
+		return nil, fmt.Errorf("unknown hash function %q: must be fnv1, fnv1a, crc32 or xxhash", name)
+	}
+}
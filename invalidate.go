@@ -0,0 +1,22 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/mailgun/groupcache/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// invalidateHandler removes a JSON array of keys from the cache of all peers
+// using at most concurrency parallel removes. groupcache removes a key from
+// the owner first and then from all other peers, failing if any of them
+// could not be reached, so failed keys should be retried.
+func invalidateHandler(group *groupcache.Group, concurrency int, removes prometheus.Counter) http.HandlerFunc {
+	return keysHandler(concurrency, func(req *http.Request, key string) error {
+		if err := group.Remove(req.Context(), key); err != nil {
+			return err
+		}
+		removes.Inc()
+		return nil
+	})
+}
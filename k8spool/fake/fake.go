@@ -0,0 +1,105 @@
+// Package fake provides an in-memory k8spool.Pool whose peers are set by
+// the test.
+package fake
+
+import (
+	"sync"
+
+	"github.com/databus23/k8sgroupcache/k8spool"
+)
+
+const updatesBuffer = 4
+
+type Pool struct {
+	mu          sync.Mutex
+	peers       []string
+	set         bool
+	synced      bool
+	closed      bool
+	subscribers []chan []string
+}
+
+var _ k8spool.Pool = (*Pool)(nil)
+
+// New returns a synced pool. If peers are given they are set right away.
+func New(peers ...string) *Pool {
+	p := &Pool{synced: true}
+	if len(peers) > 0 {
+		p.SetPeers(peers...)
+	}
+	return p
+}
+
+// SetPeers replaces the peers and sends them to all Updates channels.
+func (p *Pool) SetPeers(peers ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.peers = append([]string(nil), peers...)
+	p.set = true
+	for _, ch := range p.subscribers {
+		select {
+		case ch <- append([]string(nil), peers...):
+			continue
+		default:
+		}
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- append([]string(nil), peers...)
+	}
+}
+
+// SetSynced sets the value returned by HasSynced.
+func (p *Pool) SetSynced(synced bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.synced = synced
+}
+
+func (p *Pool) Peers() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.peers...)
+}
+
+func (p *Pool) HasSynced() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.synced
+}
+
+func (p *Pool) Alive() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return !p.closed
+}
+
+// Updates behaves like K8sPool.Updates.
+func (p *Pool) Updates() <-chan []string {
+	ch := make(chan []string, updatesBuffer)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		close(ch)
+		return ch
+	}
+	if p.set {
+		ch <- append([]string(nil), p.peers...)
+	}
+	p.subscribers = append(p.subscribers, ch)
+	return ch
+}
+
+func (p *Pool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	for _, ch := range p.subscribers {
+		close(ch)
+	}
+	p.subscribers = nil
+}
@@ -18,13 +18,31 @@ package k8spool
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"reflect"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	api_v1 "k8s.io/api/core/v1"
+	discovery_v1 "k8s.io/api/discovery/v1"
+	api_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -33,6 +51,10 @@ import (
 
 type UpdateFunc func(peers []string)
 
+type ChangeFunc func(added, removed []string)
+
+type UpdateErrFunc func(peers []string) error
+
 type Logger interface {
 	Debugf(format string, v ...any)
 	Errorf(format string, v ...any)
@@ -49,20 +71,77 @@ func (c StdLogger) Debugf(format string, v ...any) {
 	}
 
 }
+func (c StdLogger) Infof(format string, v ...any) {
+	if c.Debug || c.Error {
+		log.Printf(format, v...)
+	}
+}
+
 func (c StdLogger) Errorf(format string, v ...any) {
 	if c.Error {
 		log.Printf(format, v...)
 	}
 }
 
+// Pool is implemented by K8sPool and by the in-memory pool of the fake
+// package, which allows testing consumers without a cluster.
+type Pool interface {
+	Peers() []string
+	HasSynced() bool
+	Alive() bool
+	Updates() <-chan []string
+	Close()
+}
+
+var _ Pool = (*K8sPool)(nil)
+
 type K8sPool struct {
-	informer    cache.SharedIndexInformer
 	client      *kubernetes.Clientset
 	log         Logger
 	conf        Config
 	watchCtx    context.Context
 	watchCancel func()
 	done        chan struct{}
+
+	informerCancel    context.CancelFunc
+	listWatches       map[cache.SharedIndexInformer]*cache.ListWatch
+	running           sync.WaitGroup
+	podInformers      []cache.SharedIndexInformer
+	endpointInformers []cache.SharedIndexInformer
+	sliceInformers    []cache.SharedIndexInformer
+
+	updateMu sync.Mutex
+	update   func()
+	reloadMu sync.Mutex
+
+	mu          sync.Mutex
+	peers       []string
+	updated     chan struct{}
+	failing     map[cache.SharedIndexInformer]bool
+	lastUpdate  time.Time
+	updateCount uint64
+	eventErrors map[string]uint64
+	syncTimer   *time.Timer
+	lastSync    time.Time
+	watchErrors uint64
+	removing    map[string]*time.Timer
+
+	verifyMu    sync.Mutex
+	verified    map[string]verifyResult
+	verifying   map[string]bool
+	verifyTimer *time.Timer
+
+	weights        map[string]int
+	pendingWeights map[string]int
+
+	updateRetries int
+	retryTimer    *time.Timer
+
+	readySince     map[string]time.Time
+	stabilizeTimer *time.Timer
+
+	subscribers []chan []string
+	closed      bool
 }
 
 type WatchMechanism string
@@ -70,6 +149,42 @@ type WatchMechanism string
 const (
 	WatchEndpoints WatchMechanism = "endpoints"
 	WatchPods      WatchMechanism = "pods"
+	// WatchAuto watches both endpoints and pods, preferring peers from
+	// endpoints and filling in ready pods missing from them.
+	WatchAuto WatchMechanism = "auto"
+	// WatchStatic does not talk to Kubernetes at all and uses StaticPeers,
+	// e.g. for local development.
+	WatchStatic WatchMechanism = "static"
+	// WatchEndpointSlices watches the discovery.k8s.io/v1 EndpointSlices.
+	WatchEndpointSlices WatchMechanism = "endpointslices"
+)
+
+type AddressMode string
+
+const (
+	AddressIP       AddressMode = "ip"
+	AddressHostname AddressMode = "hostname"
+)
+
+type ReadinessSource string
+
+const (
+	ReadinessContainers   ReadinessSource = "containers"
+	ReadinessPodCondition ReadinessSource = "podCondition"
+)
+
+type AddressSource string
+
+const (
+	AddressPodIP  AddressSource = "podIP"
+	AddressHostIP AddressSource = "hostIP"
+)
+
+type AddressFormat string
+
+const (
+	FormatURL      AddressFormat = "url"
+	FormatHostPort AddressFormat = "hostport"
 )
 
 type Config struct {
@@ -80,20 +195,360 @@ type Config struct {
 	Selector   string
 	PeerScheme string
 	PeerPort   int
+
+	// SyncRetries is the number of times the initial cache sync is retried
+	// with a fresh informer before New gives up. Zero disables retries.
+	SyncRetries int
+	// SyncRetryBackoff is the delay before the first retry, doubled for each
+	// subsequent attempt. Defaults to one second.
+	SyncRetryBackoff time.Duration
+
+	// AllowEmptyPeers allows an empty peer list to replace a non-empty one
+	// while a watch or poll is failing, i.e. it has reported an error and not
+	// listed successfully since. By default the last known peers are kept in
+	// that case to avoid thrashing the cache during API server outages.
+	AllowEmptyPeers bool
+
+	// OnChange is optionally called after OnUpdate with the peers added and
+	// removed since the previous update.
+	OnChange ChangeFunc
+
+	// OnUpdateErr is like OnUpdate but may reject the peers by returning an
+	// error. The update is then retried with a fresh peer list after
+	// UpdateRetryBackoff, doubled for each further failure up to a minute,
+	// until it succeeds. It can be used instead of or next to OnUpdate.
+	OnUpdateErr UpdateErrFunc
+	// UpdateRetryBackoff defaults to one second.
+	UpdateRetryBackoff time.Duration
+
+	// AddressMode selects whether peers are addressed by IP (the default) or
+	// by their stable DNS name <hostname>.<service>.<namespace>.svc.<domain>.
+	// Hostname mode requires a headless service governing the pods (the pod's
+	// spec.subdomain when watching pods). As DNS records are only published
+	// for ready pods the service should set publishNotReadyAddresses.
+	AddressMode AddressMode
+	// ClusterDomain is the cluster DNS domain used in hostname mode.
+	// Defaults to cluster.local.
+	ClusterDomain string
+
+	// OnlyPodTargets skips endpoint addresses that are not backed by a pod.
+	OnlyPodTargets bool
+
+	// ReadyContainer restricts the readiness check of the pods mechanism to
+	// the named container. By default all containers need to be ready.
+	ReadyContainer string
+
+	// ExcludeAnnotation is the key of an annotation which excludes a pod from
+	// the peers if set to "true", e.g. DefaultExcludeAnnotation. For endpoints
+	// the annotation can only be checked if the pod is known to the pool,
+	// i.e. when using WatchAuto. Empty disables exclusion.
+	ExcludeAnnotation string
+
+	// PortAnnotation is the key of a pod annotation overriding PeerPort for
+	// that pod, e.g. DefaultPortAnnotation. Only used when watching pods.
+	PortAnnotation string
+
+	// WeightAnnotation is the key of a pod annotation setting the weight of
+	// the peer, e.g. DefaultWeightAnnotation. Pods without it have weight 1.
+	// It needs the pods, so it is only valid with WatchPods and WatchAuto.
+	// Weights are published via OnWeightedUpdate and Weights, WeightedPeers
+	// turns them into a peer list for groupcache.
+	WeightAnnotation string
+	// OnWeightedUpdate is optionally called after OnUpdate with the weight
+	// of each peer.
+	OnWeightedUpdate func(weights map[string]int)
+
+	// DryRun computes and logs the peers and their changes but does not
+	// call OnUpdate, OnWeightedUpdate or OnChange.
+	DryRun bool
+
+	// MaxPeers rejects peer lists longer than the given size, keeping the
+	// previous list, as protection against a selector matching far too many
+	// pods. The limit applies to the peers found, before VerifyPeers checks
+	// them. OnError is called for rejected lists. Zero means unlimited.
+	MaxPeers int
+
+	// AutoPort uses the port of an endpoints subset or endpoint slice if it
+	// has exactly one, falling back to PeerPort otherwise.
+	AutoPort bool
+
+	// ReadinessSource selects how the pods mechanism decides whether a pod
+	// is ready: by the status of its containers (the default, see
+	// ReadyContainer) or by the pod's Ready condition, which also takes
+	// readiness gates into account.
+	ReadinessSource ReadinessSource
+
+	// FallbackToPods switches the endpoints mechanism to watching pods if
+	// listing endpoints is forbidden, e.g. by RBAC.
+	FallbackToPods bool
+
+	// UserAgent is sent with requests to the API server. Defaults to
+	// k8sgroupcache/<version>.
+	UserAgent string
+
+	// SyncTimeout bounds the wait for the initial cache sync, per attempt
+	// if SyncRetries is set. Defaults to 30s.
+	SyncTimeout time.Duration
+	// AddressSource selects the pod status field used as peer address when
+	// watching pods in IP mode: podIP (the default) or hostIP, e.g. for
+	// pods running with hostNetwork.
+	AddressSource AddressSource
+	// StaticPeers is the peer list used by the static mechanism.
+	StaticPeers []string
+	// ServiceName restricts the endpoints watch to the endpoints (or
+	// endpoint slices) of the named service instead of selecting them by
+	// Selector or Selectors, which then only select pods.
+	ServiceName string
+	// ServingAsReady makes the endpointslices mechanism accept endpoints
+	// that are serving but not ready, e.g. terminating pods still draining
+	// requests. By default only ready endpoints are used.
+	ServingAsReady bool
+	// OwnerKind and OwnerName restrict the pods mechanism to pods controlled
+	// by the named owner. Owner references only point one level up, so the
+	// kind Deployment is matched by the name of the owning ReplicaSet,
+	// <deployment>-<pod-template-hash label>. Disabled if OwnerName is empty.
+	OwnerKind string
+	OwnerName string
+	// Selectors selects objects matching any of the given label selectors,
+	// using one informer per selector. Selector is used if empty.
+	Selectors []string
+	// Debounce delays peer updates until no informer event was received for
+	// the given duration, plus up to 10% jitter so that not all members
+	// recompute at once. Zero updates immediately on every event.
+	Debounce time.Duration
+	// OnError is called for errors of the watch, e.g. when the connection to
+	// the API server drops. The informers retry on their own.
+	OnError func(err error)
+	// RemovalDelay keeps peers that disappeared in the peer list for the
+	// given duration, so requests in flight to them can complete. Set it to
+	// the pods' termination grace period. Zero removes peers immediately.
+	RemovalDelay time.Duration
+	// ReadyStabilization requires a peer to be continuously ready for the
+	// given duration before it is added, to dampen pods flapping between
+	// ready and not ready during startup. Peers found before the informers
+	// synced are added right away. Zero adds peers immediately.
+	ReadyStabilization time.Duration
+
+	// VerifyPeers checks each peer with a GET request on VerifyPath before
+	// publishing it and drops peers not answering with a 2xx status. The
+	// checks run in the background, new peers are published once they
+	// passed.
+	VerifyPeers bool
+	// VerifyPath defaults to /health/ready.
+	VerifyPath string
+	// VerifyClient is used for the checks. Defaults to a client with a
+	// timeout of one second.
+	VerifyClient *http.Client
+	// VerifyConcurrency bounds the number of concurrent checks. Defaults to 10.
+	VerifyConcurrency int
+	// VerifyCacheTTL is how long a check result is reused. Defaults to 10s.
+	VerifyCacheTTL time.Duration
+	// VerifyTimeout bounds a round of checks. Defaults to 10s.
+	VerifyTimeout time.Duration
+
+	// ListTimeout bounds each list request to the API server. Defaults to 30s.
+	ListTimeout time.Duration
+	// ListRetries is the number of times a list failing with a transient
+	// error is retried, with exponential backoff starting at one second.
+	// Defaults to 3, -1 disables retries.
+	ListRetries int
+
+	// PollInterval lists the watched resources periodically instead of
+	// watching them, for clusters denying the watch verb. Changes are picked
+	// up with a delay of up to PollInterval. Zero watches.
+	PollInterval time.Duration
+
+	// AllNamespaces watches all namespaces if Namespace is empty. By default
+	// the pod's own namespace is detected from its service account instead,
+	// which allows running with a namespaced Role.
+	AllNamespaces bool
+
+	// BootstrapPeers are set at New, before the watch is established, so the
+	// ring starts populated instead of serving every request locally until
+	// the first sync. They are replaced by the discovered peers once the
+	// informers synced, even if none were found.
+	BootstrapPeers []string
+
+	// AddressFormat selects whether peers are emitted as URLs including
+	// PeerScheme (the default) or as bare host:port, e.g. for non-HTTP
+	// transports.
+	AddressFormat AddressFormat
+}
+
+const (
+	DefaultExcludeAnnotation = "k8sgroupcache.io/exclude"
+	DefaultPortAnnotation    = "k8sgroupcache.io/port"
+	DefaultWeightAnnotation  = "k8sgroupcache.io/weight"
+)
+
+var (
+	ErrInvalidConfig    = errors.New("invalid config")
+	ErrUnknownMechanism = errors.New("unknown watch mechanism")
+	ErrRestConfig       = errors.New("failed to get k8s rest config")
+	ErrSyncTimeout      = errors.New("timed out waiting for caches to sync")
+	ErrTooManyPeers     = errors.New("too many peers")
+)
+
+// Validate checks the config for invalid values. Zero values are valid and
+// replaced by defaults in New.
+func (c Config) Validate() error {
+	switch normalizeScheme(c.PeerScheme) {
+	case "", "http", "https":
+	default:
+		return fmt.Errorf("%w: peer scheme %q must be http or https", ErrInvalidConfig, c.PeerScheme)
+	}
+	if c.PeerPort < 0 || c.PeerPort > 65535 {
+		return fmt.Errorf("%w: peer port %d out of range", ErrInvalidConfig, c.PeerPort)
+	}
+	switch c.Mechanism {
+	case "", WatchEndpoints, WatchPods, WatchAuto, WatchStatic, WatchEndpointSlices:
+	default:
+		return fmt.Errorf("%w: %w %q", ErrInvalidConfig, ErrUnknownMechanism, c.Mechanism)
+	}
+	switch c.AddressMode {
+	case "", AddressIP, AddressHostname:
+	default:
+		return fmt.Errorf("%w: unknown address mode %q", ErrInvalidConfig, c.AddressMode)
+	}
+	switch c.AddressFormat {
+	case "", FormatURL, FormatHostPort:
+	default:
+		return fmt.Errorf("%w: unknown address format %q", ErrInvalidConfig, c.AddressFormat)
+	}
+	switch c.ReadinessSource {
+	case "", ReadinessContainers, ReadinessPodCondition:
+	default:
+		return fmt.Errorf("%w: unknown readiness source %q", ErrInvalidConfig, c.ReadinessSource)
+	}
+	switch c.AddressSource {
+	case "", AddressPodIP, AddressHostIP:
+	default:
+		return fmt.Errorf("%w: unknown address source %q", ErrInvalidConfig, c.AddressSource)
+	}
+	if c.ListRetries < -1 {
+		return fmt.Errorf("%w: list retries must be -1 or more", ErrInvalidConfig)
+	}
+	if c.MaxPeers < 0 {
+		return fmt.Errorf("%w: max peers must not be negative", ErrInvalidConfig)
+	}
+	switch c.Mechanism {
+	case WatchPods, WatchAuto:
+	default:
+		if c.WeightAnnotation != "" {
+			return fmt.Errorf("%w: weight annotation needs the pods, watch them with %s or %s", ErrInvalidConfig, WatchPods, WatchAuto)
+		}
+	}
+	if c.VerifyConcurrency < 0 {
+		return fmt.Errorf("%w: verify concurrency must not be negative", ErrInvalidConfig)
+	}
+	if c.VerifyTimeout < 0 {
+		return fmt.Errorf("%w: verify timeout must not be negative", ErrInvalidConfig)
+	}
+	if c.RemovalDelay < 0 {
+		return fmt.Errorf("%w: removal delay must not be negative", ErrInvalidConfig)
+	}
+	if c.Debounce < 0 {
+		return fmt.Errorf("%w: debounce must not be negative", ErrInvalidConfig)
+	}
+	if c.SyncTimeout < 0 {
+		return fmt.Errorf("%w: sync timeout must not be negative", ErrInvalidConfig)
+	}
+	if c.SyncRetries < 0 {
+		return fmt.Errorf("%w: sync retries must not be negative", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// PeerURL returns the URL of a peer with path appended, e.g. a groupcache
+// base path or a health check path. IPv6 addresses are bracketed.
+func PeerURL(scheme, host string, port int, path string) string {
+	u := url.URL{Scheme: scheme, Host: net.JoinHostPort(host, strconv.Itoa(port)), Path: path}
+	return u.String()
+}
+
+// WeightedPeers returns the sorted peers of weights, each repeated according
+// to its weight, to be passed to groupcache's HTTPPool.Set. The pool places
+// peers on its hash ring by their URL, so repetitions carry their number as
+// user info, e.g. http://2@10.0.0.1:8080. groupcache's HTTPPool treats
+// repetitions as separate peers, use a picker resolving them with PeerOf
+// like the cache package's Pool.
+func WeightedPeers(weights map[string]int) []string {
+	var peers []string
+	for peer, weight := range weights {
+		peers = append(peers, peer)
+		for i := 2; i <= weight; i++ {
+			peers = append(peers, weightedPeer(peer, i))
+		}
+	}
+	sort.Strings(peers)
+	return peers
+}
+
+func weightedPeer(peer string, i int) string {
+	if scheme, host, ok := strings.Cut(peer, "://"); ok {
+		return scheme + "://" + strconv.Itoa(i) + "@" + host
+	}
+	return strconv.Itoa(i) + "@" + peer
+}
+
+// PeerOf returns the peer a repetition returned by WeightedPeers stands for,
+// other peers are returned as is.
+func PeerOf(peer string) string {
+	scheme, host, ok := strings.Cut(peer, "://")
+	if !ok {
+		scheme, host = "", peer
+	}
+	if _, h, found := strings.Cut(host, "@"); found {
+		host = h
+	}
+	if !ok {
+		return host
+	}
+	return scheme + "://" + host
+}
+
+// peerAddress formats a peer according to AddressFormat.
+func (e *K8sPool) peerAddress(host string, port int) string {
+	if e.conf.AddressFormat == FormatHostPort {
+		return net.JoinHostPort(host, strconv.Itoa(port))
+	}
+	return PeerURL(e.conf.PeerScheme, host, port, "")
+}
+
+// normalizeScheme accepts schemes like "HTTP" or "https://".
+func normalizeScheme(scheme string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(scheme)), "://")
 }
 
 func New(conf Config) (*K8sPool, error) {
-	config, err := rest.InClusterConfig()
-	if err != nil {
-		return nil, fmt.Errorf("Failed to get k8s rest config: %w", err)
+	return NewWithContext(context.Background(), conf)
+}
+
+// NewWithContext is like New but ties the lifetime of the watcher to ctx.
+// Cancelling ctx stops the watch just like calling Close.
+func NewWithContext(parent context.Context, conf Config) (*K8sPool, error) {
+	conf.PeerScheme = normalizeScheme(conf.PeerScheme)
+	if err := conf.Validate(); err != nil {
+		return nil, err
 	}
-	// creates the client
-	client, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		return nil, fmt.Errorf("Failed to create k8s client: %w", err)
+	var client *kubernetes.Clientset
+	if conf.Mechanism != WatchStatic {
+		config, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrRestConfig, err)
+		}
+		config.UserAgent = conf.UserAgent
+		if config.UserAgent == "" {
+			config.UserAgent = defaultUserAgent()
+		}
+		// creates the client
+		client, err = kubernetes.NewForConfig(config)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create k8s client: %w", err)
+		}
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(parent)
 
 	if conf.Logger == nil {
 		conf.Logger = &StdLogger{Error: true}
@@ -104,154 +559,1494 @@ func New(conf Config) (*K8sPool, error) {
 	if conf.PeerPort == 0 {
 		conf.PeerPort = 8080
 	}
+	if conf.ClusterDomain == "" {
+		conf.ClusterDomain = "cluster.local"
+	}
+	if conf.SyncTimeout == 0 {
+		conf.SyncTimeout = 30 * time.Second
+	}
+	if conf.VerifyPath == "" {
+		conf.VerifyPath = "/health/ready"
+	}
+	if conf.VerifyClient == nil {
+		conf.VerifyClient = &http.Client{Timeout: time.Second}
+	}
+	if conf.VerifyConcurrency == 0 {
+		conf.VerifyConcurrency = 10
+	}
+	if conf.VerifyCacheTTL == 0 {
+		conf.VerifyCacheTTL = 10 * time.Second
+	}
+	if conf.VerifyTimeout == 0 {
+		conf.VerifyTimeout = 10 * time.Second
+	}
+	if conf.ListTimeout == 0 {
+		conf.ListTimeout = 30 * time.Second
+	}
+	if conf.ListRetries == 0 {
+		conf.ListRetries = 3
+	}
+	if conf.SyncRetryBackoff == 0 {
+		conf.SyncRetryBackoff = time.Second
+	}
+	if conf.UpdateRetryBackoff == 0 {
+		conf.UpdateRetryBackoff = time.Second
+	}
+	detectNamespace := conf.Namespace == "" && !conf.AllNamespaces && conf.Mechanism != WatchStatic
+	if detectNamespace {
+		if ns, err := os.ReadFile(serviceAccountNamespaceFile); err == nil {
+			conf.Namespace = strings.TrimSpace(string(ns))
+		}
+	}
 
 	pool := &K8sPool{
 		done:        make(chan struct{}),
+		updated:     make(chan struct{}),
 		log:         conf.Logger,
 		client:      client,
 		conf:        conf,
 		watchCtx:    ctx,
 		watchCancel: cancel,
 	}
+	if detectNamespace {
+		if conf.Namespace != "" {
+			pool.infof("Using namespace %s detected from the service account", conf.Namespace)
+		} else {
+			pool.infof("Failed to detect own namespace, watching all namespaces")
+		}
+	}
+	pool.running.Add(1)
+	go func() {
+		defer pool.running.Done()
+		<-ctx.Done()
+		close(pool.done)
+		pool.mu.Lock()
+		pool.closed = true
+		for _, ch := range pool.subscribers {
+			close(ch)
+		}
+		pool.subscribers = nil
+		pool.mu.Unlock()
+	}()
 
-	return pool, pool.start()
+	if len(conf.BootstrapPeers) > 0 {
+		pool.updateMu.Lock()
+		pool.setPeers(append([]string(nil), conf.BootstrapPeers...))
+		pool.updateMu.Unlock()
+	}
+
+	if err := pool.start(conf); err != nil {
+		pool.watchCancel()
+		return pool, err
+	}
+	return pool, nil
+}
+
+func defaultUserAgent() string {
+	version := "unknown"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if info.Main.Path == modulePath {
+			version = info.Main.Version
+		}
+		for _, dep := range info.Deps {
+			if dep.Path == modulePath {
+				version = dep.Version
+			}
+		}
+	}
+	return "k8sgroupcache/" + version
 }
 
-func (e *K8sPool) start() error {
-	switch e.conf.Mechanism {
+const modulePath = "github.com/databus23/k8sgroupcache"
+
+const serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+func (e *K8sPool) start(conf Config) error {
+	switch conf.Mechanism {
 	case "", WatchEndpoints:
-		return e.startEndpointWatch()
+		if conf.FallbackToPods && e.endpointsForbidden(&conf) {
+			e.infof("Listing endpoints is forbidden, falling back to watching pods")
+			e.mu.Lock()
+			e.conf.Mechanism = WatchPods
+			e.mu.Unlock()
+			conf.Mechanism = WatchPods
+			return e.start(conf)
+		}
+		return e.startWatch(conf, e.updatePeersFromEndpoints, func(conf *Config) (s informerSet) {
+			s.endpoints = e.newServiceInformers(conf, &api_v1.Endpoints{}, e.endpointListWatch)
+			return s
+		})
 	case WatchPods:
-		return e.startPodWatch()
+		return e.startWatch(conf, e.updatePeersFromPods, func(conf *Config) (s informerSet) {
+			s.pods = e.newInformers(conf, &api_v1.Pod{}, e.podListWatch)
+			return s
+		})
+	case WatchAuto:
+		return e.startWatch(conf, e.updatePeersAuto, func(conf *Config) (s informerSet) {
+			s.endpoints = e.newServiceInformers(conf, &api_v1.Endpoints{}, e.endpointListWatch)
+			s.pods = e.newInformers(conf, &api_v1.Pod{}, e.podListWatch)
+			return s
+		})
+	case WatchEndpointSlices:
+		return e.startWatch(conf, e.updatePeersFromEndpointSlices, func(conf *Config) (s informerSet) {
+			s.slices = e.newServiceInformers(conf, &discovery_v1.EndpointSlice{}, e.endpointSliceListWatch)
+			return s
+		})
+	case WatchStatic:
+		e.setUpdate(e.updatePeersStatic)
+		return nil
 	default:
-		return fmt.Errorf("unknown value for watch mechanism: %s", e.conf.Mechanism)
+		return fmt.Errorf("%w: %s", ErrUnknownMechanism, conf.Mechanism)
+	}
+}
+
+// endpointsForbidden reports whether listing endpoints fails with 403.
+func (e *K8sPool) endpointsForbidden(conf *Config) bool {
+	_, err := e.endpointListWatch(conf, conf.Selector).List(meta_v1.ListOptions{Limit: 1})
+	return api_errors.IsForbidden(err)
+}
+
+// informerSet holds the informers of one watch.
+type informerSet struct {
+	endpoints []cache.SharedIndexInformer
+	slices    []cache.SharedIndexInformer
+	pods      []cache.SharedIndexInformer
+}
+
+func (s informerSet) all() []cache.SharedIndexInformer {
+	informers := append([]cache.SharedIndexInformer(nil), s.endpoints...)
+	informers = append(informers, s.slices...)
+	return append(informers, s.pods...)
+}
+
+// startWatch creates the informers for conf using createInformers and waits
+// for their initial sync, retrying with fresh informers if configured. Only
+// then they replace the running informers, so these keep providing the peers
+// until the new ones are ready and stay in place if the sync fails. Informer
+// events do not update the peers before the first sync, afterwards the peers
+// are computed by update.
+func (e *K8sPool) startWatch(conf Config, update func(), createInformers func(conf *Config) informerSet) error {
+	if conf.PollInterval > 0 {
+		return e.startPoll(conf, update, createInformers)
+	}
+	backoff := conf.SyncRetryBackoff
+	for attempt := 0; ; attempt++ {
+		informers := createInformers(&conf)
+		cancel, err := e.runInformers(informers.all())
+		if err == nil {
+			e.install(conf, update, informers, cancel)
+			return nil
+		}
+		if attempt >= conf.SyncRetries || e.watchCtx.Err() != nil {
+			return err
+		}
+		e.log.Debugf("Retrying cache sync in %s (attempt %d/%d): %s", backoff, attempt+1, conf.SyncRetries, err)
+		select {
+		case <-time.After(backoff):
+		case <-e.done:
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// startPoll is like startWatch but instead of running the informers their
+// stores are replaced with a fresh list every PollInterval.
+func (e *K8sPool) startPoll(conf Config, update func(), createInformers func(conf *Config) informerSet) error {
+	informers := createInformers(&conf)
+	if err := e.poll(informers.all()); err != nil {
+		e.forgetInformers(informers.all())
+		return fmt.Errorf("Failed to list peers: %w", err)
+	}
+	pollCtx, pollCancel := context.WithCancel(e.watchCtx)
+	e.install(conf, update, informers, pollCancel)
+
+	e.goRunning(func() {
+		ticker := time.NewTicker(conf.PollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-pollCtx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := e.poll(informers.all()); err != nil {
+				if pollCtx.Err() != nil {
+					return
+				}
+				e.mu.Lock()
+				e.watchErrors++
+				e.mu.Unlock()
+				e.log.Errorf("Poll failed: %s", err)
+				if e.conf.OnError != nil {
+					e.conf.OnError(err)
+				}
+				continue
+			}
+			e.scheduleSync()
+		}
+	})
+	return nil
+}
+
+// install stops the running informers, replaces them by informers, which
+// already synced, and recomputes the peers with update. cancel stops
+// informers.
+func (e *K8sPool) install(conf Config, update func(), informers informerSet, cancel context.CancelFunc) {
+	e.updateMu.Lock()
+	e.mu.Lock()
+	if e.informerCancel != nil {
+		e.informerCancel()
+	}
+	old := e.informers()
+	e.informerCancel = cancel
+	e.endpointInformers = informers.endpoints
+	e.sliceInformers = informers.slices
+	e.podInformers = informers.pods
+	e.conf.Namespace = conf.Namespace
+	e.conf.Selector = conf.Selector
+	e.conf.Selectors = conf.Selectors
+	e.update = update
+	e.mu.Unlock()
+	e.updateMu.Unlock()
+	e.forgetInformers(old)
+	e.Sync()
+}
+
+// setUpdate sets the function computing the peers and calls it.
+func (e *K8sPool) setUpdate(update func()) {
+	e.updateMu.Lock()
+	e.update = update
+	e.updateMu.Unlock()
+	e.Sync()
+}
+
+// poll replaces the stores of informers with the listed objects.
+func (e *K8sPool) poll(informers []cache.SharedIndexInformer) error {
+	for _, informer := range informers {
+		e.mu.Lock()
+		listWatch := e.listWatches[informer]
+		e.mu.Unlock()
+		list, err := listWatch.List(meta_v1.ListOptions{})
+		e.setFailing(informer, err != nil)
+		if err != nil {
+			return err
+		}
+		objs, err := meta.ExtractList(list)
+		if err != nil {
+			return err
+		}
+		items := make([]interface{}, len(objs))
+		for i, obj := range objs {
+			items[i] = obj
+		}
+		var version string
+		if accessor, err := meta.ListAccessor(list); err == nil {
+			version = accessor.GetResourceVersion()
+		}
+		if err := informer.GetStore().Replace(items, version); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
-func (e *K8sPool) startGenericWatch(objType runtime.Object, listWatch *cache.ListWatch, updateFunc func()) error {
-	e.informer = cache.NewSharedIndexInformer(
+// forgetInformers drops the list watches and the watch state of informers
+// no longer in use.
+func (e *K8sPool) forgetInformers(informers []cache.SharedIndexInformer) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, informer := range informers {
+		delete(e.listWatches, informer)
+		delete(e.failing, informer)
+	}
+}
+
+// setFailing records whether the last list or watch of informer failed.
+func (e *K8sPool) setFailing(informer cache.SharedIndexInformer, failing bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !failing {
+		delete(e.failing, informer)
+		return
+	}
+	if e.failing == nil {
+		e.failing = map[cache.SharedIndexInformer]bool{}
+	}
+	e.failing[informer] = true
+}
+
+// newInformers creates an informer per selector. Objects matching several
+// selectors end up in several stores, the resulting duplicate peers are
+// removed by setPeers.
+func (e *K8sPool) newInformers(conf *Config, objType runtime.Object, listWatch func(conf *Config, selector string) *cache.ListWatch) []cache.SharedIndexInformer {
+	selectors := conf.Selectors
+	if len(selectors) == 0 {
+		selectors = []string{conf.Selector}
+	}
+	var informers []cache.SharedIndexInformer
+	for _, selector := range selectors {
+		informers = append(informers, e.newInformer(objType, listWatch(conf, selector)))
+	}
+	return informers
+}
+
+// newServiceInformers is like newInformers for endpoints and endpoint slices,
+// which are selected by ServiceName instead of the selectors if it is set.
+// The informers of all selectors would then watch the same objects, so a
+// single one is created.
+func (e *K8sPool) newServiceInformers(conf *Config, objType runtime.Object, listWatch func(conf *Config, selector string) *cache.ListWatch) []cache.SharedIndexInformer {
+	if conf.ServiceName == "" {
+		return e.newInformers(conf, objType, listWatch)
+	}
+	return []cache.SharedIndexInformer{e.newInformer(objType, listWatch(conf, ""))}
+}
+
+func (e *K8sPool) newInformer(objType runtime.Object, listWatch *cache.ListWatch) cache.SharedIndexInformer {
+	var informer cache.SharedIndexInformer
+	if e.conf.PollInterval <= 0 {
+		// The reflector lists again after a failed watch, a successful list
+		// means the store is current again.
+		list := listWatch.ListFunc
+		listWatch.ListFunc = func(options meta_v1.ListOptions) (runtime.Object, error) {
+			obj, err := list(options)
+			if err == nil {
+				e.setFailing(informer, false)
+			}
+			return obj, err
+		}
+	}
+	informer = cache.NewSharedIndexInformer(
 		listWatch,
 		objType,
 		0, //Skip resync
 		cache.Indexers{},
 	)
+	if e.conf.PollInterval > 0 {
+		e.mu.Lock()
+		if e.listWatches == nil {
+			e.listWatches = map[cache.SharedIndexInformer]*cache.ListWatch{}
+		}
+		e.listWatches[informer] = listWatch
+		e.mu.Unlock()
+		return informer
+	}
 
-	e.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			key, err := cache.MetaNamespaceKeyFunc(obj)
-			e.log.Debugf("Queue (Add) '%s' - %v", key, err)
+			logger := e.logWith("event", "add", "key", key)
+			logger.Debugf("Queue (Add) '%s' - %v", key, err)
 			if err != nil {
-				e.log.Errorf("while calling MetaNamespaceKeyFunc(): %s", err)
+				logger.Errorf("while calling MetaNamespaceKeyFunc(): %s", err)
+				e.eventError(EventErrorKeyFunc)
 				return
 			}
-			updateFunc()
+			e.scheduleSync()
 		},
 		UpdateFunc: func(obj, new interface{}) {
 			key, err := cache.MetaNamespaceKeyFunc(obj)
-			e.log.Debugf("Queue (Update) '%s' - %v", key, err)
+			logger := e.logWith("event", "update", "key", key)
+			logger.Debugf("Queue (Update) '%s' - %v", key, err)
 			if err != nil {
-				e.log.Errorf("while calling MetaNamespaceKeyFunc(): %s", err)
+				logger.Errorf("while calling MetaNamespaceKeyFunc(): %s", err)
+				e.eventError(EventErrorKeyFunc)
 				return
 			}
-			updateFunc()
+			e.scheduleSync()
 		},
 		DeleteFunc: func(obj interface{}) {
 			key, err := cache.MetaNamespaceKeyFunc(obj)
-			e.log.Debugf("Queue (Delete) '%s' - %v", key, err)
+			logger := e.logWith("event", "delete", "key", key)
+			logger.Debugf("Queue (Delete) '%s' - %v", key, err)
 			if err != nil {
-				e.log.Errorf("while calling MetaNamespaceKeyFunc(): %s", err)
+				logger.Errorf("while calling MetaNamespaceKeyFunc(): %s", err)
+				e.eventError(EventErrorKeyFunc)
 				return
 			}
-			updateFunc()
+			e.scheduleSync()
 		},
 	})
 
-	go e.informer.Run(e.done)
+	informer.SetWatchErrorHandler(func(r *cache.Reflector, err error) {
+		e.mu.Lock()
+		e.watchErrors++
+		e.mu.Unlock()
+		e.setFailing(informer, true)
+		e.log.Errorf("Watch failed: %s", err)
+		if e.conf.OnError != nil {
+			e.conf.OnError(err)
+		}
+		cache.DefaultWatchErrorHandler(r, err)
+	})
 
-	if !cache.WaitForCacheSync(e.done, e.informer.HasSynced) {
-		close(e.done)
-		return fmt.Errorf("timed out waiting for caches to sync")
+	return informer
+}
+
+// runInformers runs the informers and waits for their initial sync, returning
+// the function stopping them. If the sync fails the informers are stopped
+// again so fresh ones can be created.
+func (e *K8sPool) runInformers(informers []cache.SharedIndexInformer) (context.CancelFunc, error) {
+	informerCtx, informerCancel := context.WithCancel(e.watchCtx)
+	synced := make([]cache.InformerSynced, len(informers))
+	for i, informer := range informers {
+		informer := informer
+		if !e.goRunning(func() { informer.Run(informerCtx.Done()) }) {
+			informerCancel()
+			return nil, fmt.Errorf("pool closed while starting informers")
+		}
+		synced[i] = informer.HasSynced
 	}
 
-	return nil
+	syncCtx, cancel := context.WithTimeout(informerCtx, e.conf.SyncTimeout)
+	defer cancel()
+	if !cache.WaitForCacheSync(syncCtx.Done(), synced...) {
+		informerCancel()
+		return nil, fmt.Errorf("%w after %s", ErrSyncTimeout, e.conf.SyncTimeout)
+	}
+	return informerCancel, nil
+}
+
+// goRunning runs f in a goroutine tracked by running unless the pool is
+// closed, reporting whether it started. closed is checked and running added
+// to under mu, which Close takes to set closed before waiting for running,
+// so no goroutine is added once Close waits.
+func (e *K8sPool) goRunning(f func()) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return false
+	}
+	e.running.Add(1)
+	go func() {
+		defer e.running.Done()
+		f()
+	}()
+	return true
+}
+
+// informers returns all informers of the pool. e.mu must be held.
+func (e *K8sPool) informers() []cache.SharedIndexInformer {
+	return informerSet{endpoints: e.endpointInformers, slices: e.sliceInformers, pods: e.podInformers}.all()
+}
+
+// listStores returns the objects in the stores of the given informers.
+func (e *K8sPool) listStores(informers *[]cache.SharedIndexInformer) []interface{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var objs []interface{}
+	for _, informer := range *informers {
+		objs = append(objs, informer.GetStore().List()...)
+	}
+	return objs
+}
+
+// list calls fn with a context bounded by ListTimeout that is cancelled by
+// Close, retrying transient errors.
+func (e *K8sPool) list(fn func(ctx context.Context) (runtime.Object, error)) (runtime.Object, error) {
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(e.watchCtx, e.conf.ListTimeout)
+		obj, err := fn(ctx)
+		cancel()
+		if err == nil || attempt >= e.conf.ListRetries || !transient(err) || e.watchCtx.Err() != nil {
+			return obj, err
+		}
+		e.log.Debugf("Retrying list in %s (attempt %d/%d): %s", backoff, attempt+1, e.conf.ListRetries, err)
+		select {
+		case <-time.After(backoff):
+		case <-e.done:
+			return nil, err
+		}
+		backoff *= 2
+	}
+}
+
+func transient(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) ||
+		api_errors.IsServerTimeout(err) ||
+		api_errors.IsTimeout(err) ||
+		api_errors.IsTooManyRequests(err) ||
+		api_errors.IsInternalError(err) ||
+		api_errors.IsServiceUnavailable(err) ||
+		utilnet.IsConnectionRefused(err) ||
+		utilnet.IsConnectionReset(err)
+}
+
+func (e *K8sPool) podListWatch(conf *Config, selector string) *cache.ListWatch {
+	namespace := conf.Namespace
+	return &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			options.LabelSelector = selector
+			return e.list(func(ctx context.Context) (runtime.Object, error) {
+				return e.client.CoreV1().Pods(namespace).List(ctx, options)
+			})
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			options.LabelSelector = selector
+			return e.client.CoreV1().Pods(namespace).Watch(e.watchCtx, options)
+		},
+	}
 }
 
-func (e *K8sPool) startPodWatch() error {
-	listWatch := &cache.ListWatch{
+func (e *K8sPool) endpointListWatch(conf *Config, selector string) *cache.ListWatch {
+	namespace := conf.Namespace
+	var fieldSelector string
+	if conf.ServiceName != "" {
+		selector, fieldSelector = "", fields.OneTermEqualSelector("metadata.name", conf.ServiceName).String()
+	}
+	return &cache.ListWatch{
 		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
-			options.LabelSelector = e.conf.Selector
-			return e.client.CoreV1().Pods(e.conf.Namespace).List(context.Background(), options)
+			options.LabelSelector = selector
+			options.FieldSelector = fieldSelector
+			return e.list(func(ctx context.Context) (runtime.Object, error) {
+				return e.client.CoreV1().Endpoints(namespace).List(ctx, options)
+			})
 		},
 		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
-			options.LabelSelector = e.conf.Selector
-			return e.client.CoreV1().Pods(e.conf.Namespace).Watch(e.watchCtx, options)
+			options.LabelSelector = selector
+			options.FieldSelector = fieldSelector
+			return e.client.CoreV1().Endpoints(namespace).Watch(e.watchCtx, options)
 		},
 	}
-	return e.startGenericWatch(&api_v1.Pod{}, listWatch, e.updatePeersFromPods)
 }
 
-func (e *K8sPool) startEndpointWatch() error {
-	listWatch := &cache.ListWatch{
+func (e *K8sPool) endpointSliceListWatch(conf *Config, selector string) *cache.ListWatch {
+	namespace := conf.Namespace
+	if conf.ServiceName != "" {
+		selector = labels.Set{discovery_v1.LabelServiceName: conf.ServiceName}.String()
+	}
+	return &cache.ListWatch{
 		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
-			options.LabelSelector = e.conf.Selector
-			return e.client.CoreV1().Endpoints(e.conf.Namespace).List(context.Background(), options)
+			options.LabelSelector = selector
+			return e.list(func(ctx context.Context) (runtime.Object, error) {
+				return e.client.DiscoveryV1().EndpointSlices(namespace).List(ctx, options)
+			})
 		},
 		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
-			options.LabelSelector = e.conf.Selector
-			return e.client.CoreV1().Endpoints(e.conf.Namespace).Watch(e.watchCtx, options)
+			options.LabelSelector = selector
+			return e.client.DiscoveryV1().EndpointSlices(namespace).Watch(e.watchCtx, options)
 		},
 	}
-	return e.startGenericWatch(&api_v1.Endpoints{}, listWatch, e.updatePeersFromEndpoints)
 }
 
 func (e *K8sPool) updatePeersFromPods() {
+	e.setPeers(e.stabilize(e.peersFromPods(nil)))
+}
+
+func (e *K8sPool) updatePeersFromEndpoints() {
+	peers, _ := e.peersFromEndpoints()
+	e.setPeers(e.stabilize(peers))
+}
+
+func (e *K8sPool) updatePeersStatic() {
+	e.mu.Lock()
+	peers := append([]string(nil), e.conf.StaticPeers...)
+	e.mu.Unlock()
+	e.setPeers(peers)
+}
+
+func (e *K8sPool) updatePeersFromEndpointSlices() {
+	e.setPeers(e.stabilize(e.peersFromEndpointSlices()))
+}
+
+// updatePeersAuto prefers peers from endpoints and fills in peers derived
+// from pods that are missing from the endpoints, e.g. because the endpoints
+// controller lags behind pod readiness. Pods are matched to endpoint
+// addresses by their target reference or IP, as the peer addresses may differ
+// in port or host name.
+func (e *K8sPool) updatePeersAuto() {
+	peers, covered := e.peersFromEndpoints()
+	podPeers := e.peersFromPods(covered)
+	if len(peers) == 0 && len(podPeers) > 0 {
+		e.log.Debugf("No peers found in endpoints, falling back to %d peers from pods", len(podPeers))
+	}
+	e.setPeers(e.stabilize(append(peers, podPeers...)))
+}
+
+// peersFromPods returns the peers of ready pods. Pods whose namespace/name or
+// IP is in skip are left out.
+func (e *K8sPool) peersFromPods(skip map[string]bool) []string {
 	e.log.Debugf("Fetching peer list from pods API")
 	var peers []string
 main:
-	for _, obj := range e.informer.GetStore().List() {
+	for _, obj := range e.listStores(&e.podInformers) {
 		pod, ok := obj.(*api_v1.Pod)
 		if !ok {
-			e.log.Errorf("expected type v1.Endpoints got '%s' instead", reflect.TypeOf(obj).String())
+			e.log.Errorf("Skipping object: expected type v1.Pod got '%T' instead", obj)
+			e.eventError(EventErrorTypeAssert)
+			continue
 		}
 
-		peer := fmt.Sprintf("%s://%s:%d", e.conf.PeerScheme, pod.Status.PodIP, e.conf.PeerPort)
+		if skip[pod.Namespace+"/"+pod.Name] || (pod.Status.PodIP != "" && skip[pod.Status.PodIP]) {
+			e.log.Debugf("Skipping pod %s/%s because it is already a peer via endpoints", pod.Namespace, pod.Name)
+			continue
+		}
+		if e.excluded(pod) {
+			e.log.Debugf("Skipping pod %s/%s because it is excluded by annotation", pod.Namespace, pod.Name)
+			continue
+		}
+		if !e.ownedBy(pod) {
+			e.log.Debugf("Skipping pod %s/%s because it is not owned by %s %s", pod.Namespace, pod.Name, e.conf.OwnerKind, e.conf.OwnerName)
+			continue
+		}
+		if pod.Status.Phase != api_v1.PodRunning {
+			e.log.Debugf("Skipping pod %s/%s because it is in phase %s", pod.Namespace, pod.Name, pod.Status.Phase)
+			continue
+		}
+		host := pod.Status.PodIP
+		if e.conf.AddressSource == AddressHostIP {
+			host = pod.Status.HostIP
+		}
+		if host == "" {
+			e.log.Debugf("Skipping pod %s/%s because it has no IP", pod.Namespace, pod.Name)
+			continue
+		}
+
+		if e.conf.AddressMode == AddressHostname {
+			if pod.Spec.Subdomain == "" {
+				e.log.Debugf("Skipping pod %s/%s because it has no subdomain", pod.Namespace, pod.Name)
+				continue
+			}
+			hostname := pod.Spec.Hostname
+			if hostname == "" {
+				hostname = pod.Name
+			}
+			host = e.serviceHostname(hostname, pod.Spec.Subdomain, pod.Namespace)
+		}
+		peer := e.peerAddress(host, e.podPort(pod))
 
-		// if containers are not ready or not running then skip this peer
-		for _, status := range pod.Status.ContainerStatuses {
-			if !status.Ready || status.State.Running == nil {
-				e.log.Debugf("Skipping peer because it's not ready or not running: %+v\n", peer)
-				continue main
+		if e.conf.ReadinessSource == ReadinessPodCondition {
+			if !podReady(pod) {
+				e.logWith("peer", peer).Debugf("Skipping peer because its pod is not ready: %+v\n", peer)
+				continue
+			}
+		} else {
+			// if containers are not ready or not running then skip this peer
+			found := e.conf.ReadyContainer == ""
+			for _, status := range pod.Status.ContainerStatuses {
+				if e.conf.ReadyContainer != "" {
+					if status.Name != e.conf.ReadyContainer {
+						continue
+					}
+					found = true
+				}
+				if !status.Ready || status.State.Running == nil {
+					e.logWith("peer", peer).Debugf("Skipping peer because it's not ready or not running: %+v\n", peer)
+					continue main
+				}
+			}
+			if !found {
+				e.log.Debugf("Skipping peer because container %s has no status: %+v\n", e.conf.ReadyContainer, peer)
+				continue
 			}
 		}
 
-		e.log.Debugf("Peer: %+v\n", peer)
+		e.logWith("peer", peer).Debugf("Peer: %+v\n", peer)
+		e.weigh(peer, pod)
 		peers = append(peers, peer)
 	}
-	e.conf.OnUpdate(peers)
+	return peers
 }
 
-func (e *K8sPool) updatePeersFromEndpoints() {
+// peersFromEndpoints returns the peers of ready endpoint addresses and the
+// namespace/name of the pods and the IPs they belong to.
+func (e *K8sPool) peersFromEndpoints() ([]string, map[string]bool) {
 	e.log.Debugf("Fetching peer list from endpoints API")
 	var peers []string
-	for _, obj := range e.informer.GetStore().List() {
+	covered := map[string]bool{}
+	for _, obj := range e.listStores(&e.endpointInformers) {
 		endpoint, ok := obj.(*api_v1.Endpoints)
 		if !ok {
-			e.log.Errorf("expected type v1.Endpoints got '%s' instead", reflect.TypeOf(obj).String())
+			e.log.Errorf("Skipping object: expected type v1.Endpoints got '%T' instead", obj)
+			e.eventError(EventErrorTypeAssert)
+			continue
 		}
 
 		for _, s := range endpoint.Subsets {
+			port := e.conf.PeerPort
+			if e.conf.AutoPort && len(s.Ports) == 1 {
+				port = int(s.Ports[0].Port)
+				e.log.Debugf("Using port %d of endpoints %s/%s", port, endpoint.Namespace, endpoint.Name)
+			}
 			for _, addr := range s.Addresses {
-				peer := fmt.Sprintf("%s://%s:%d", e.conf.PeerScheme, addr.IP, e.conf.PeerPort)
+				if e.conf.OnlyPodTargets && (addr.TargetRef == nil || addr.TargetRef.Kind != "Pod") {
+					e.log.Debugf("Skipping address %s because it is not backed by a pod", addr.IP)
+					continue
+				}
+				if pod := e.podFor(addr.TargetRef); pod != nil && e.excluded(pod) {
+					e.log.Debugf("Skipping address %s because pod %s/%s is excluded by annotation", addr.IP, pod.Namespace, pod.Name)
+					continue
+				}
+				host := addr.IP
+				if e.conf.AddressMode == AddressHostname {
+					hostname := addr.Hostname
+					if hostname == "" && addr.TargetRef != nil {
+						hostname = addr.TargetRef.Name
+					}
+					if hostname == "" {
+						e.log.Debugf("Skipping address %s because it has no hostname", addr.IP)
+						continue
+					}
+					host = e.serviceHostname(hostname, endpoint.Name, endpoint.Namespace)
+				}
+				peer := e.peerAddress(host, port)
+				e.weigh(peer, e.podFor(addr.TargetRef))
 
 				peers = append(peers, peer)
-				e.log.Debugf("Peer: %+v\n", peer)
+				covered[addr.IP] = true
+				if ref := addr.TargetRef; ref != nil && ref.Kind == "Pod" {
+					ns := ref.Namespace
+					if ns == "" {
+						ns = endpoint.Namespace
+					}
+					covered[ns+"/"+ref.Name] = true
+				}
+				e.logWith("peer", peer).Debugf("Peer: %+v\n", peer)
+			}
+		}
+	}
+	return peers, covered
+}
+
+func (e *K8sPool) podPort(pod *api_v1.Pod) int {
+	if e.conf.PortAnnotation == "" {
+		return e.conf.PeerPort
+	}
+	value, ok := pod.Annotations[e.conf.PortAnnotation]
+	if !ok {
+		return e.conf.PeerPort
+	}
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		e.log.Errorf("Invalid port annotation %q on pod %s/%s, using port %d", value, pod.Namespace, pod.Name, e.conf.PeerPort)
+		return e.conf.PeerPort
+	}
+	return port
+}
+
+// weigh records the weight annotated on pod for peer, to be published by
+// the next setPeers. It must only be called from the update function.
+func (e *K8sPool) weigh(peer string, pod *api_v1.Pod) {
+	if e.conf.WeightAnnotation == "" || pod == nil {
+		return
+	}
+	value, ok := pod.Annotations[e.conf.WeightAnnotation]
+	if !ok {
+		return
+	}
+	weight, err := strconv.Atoi(value)
+	if err != nil || weight < 1 {
+		e.log.Errorf("Invalid weight annotation %q on pod %s/%s, using weight 1", value, pod.Namespace, pod.Name)
+		return
+	}
+	if e.pendingWeights == nil {
+		e.pendingWeights = map[string]int{}
+	}
+	e.pendingWeights[peer] = weight
+}
+
+// stabilize drops peers that have not been ready for ReadyStabilization and
+// schedules a resync for when the next of them is due. It must only be
+// called from the update function.
+func (e *K8sPool) stabilize(peers []string) []string {
+	if e.conf.ReadyStabilization <= 0 {
+		return peers
+	}
+	now := time.Now()
+	initial := e.readySince == nil || !e.HasSynced()
+	since := make(map[string]time.Time, len(peers))
+	var stable []string
+	var next time.Duration
+	for _, peer := range peers {
+		t, ok := e.readySince[peer]
+		if !ok && !initial {
+			t = now
+		}
+		since[peer] = t
+		if wait := e.conf.ReadyStabilization - now.Sub(t); wait > 0 {
+			e.logWith("peer", peer).Debugf("Skipping peer %s until it is ready for %s", peer, e.conf.ReadyStabilization)
+			if next == 0 || wait < next {
+				next = wait
 			}
+			continue
 		}
+		stable = append(stable, peer)
 	}
-	e.conf.OnUpdate(peers)
+	e.readySince = since
+	if next > 0 {
+		if e.stabilizeTimer == nil {
+			e.stabilizeTimer = time.AfterFunc(next, func() {
+				if e.watchCtx.Err() == nil {
+					e.scheduleSync()
+				}
+			})
+		} else {
+			e.stabilizeTimer.Reset(next)
+		}
+	}
+	return stable
+}
+
+func podReady(pod *api_v1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == api_v1.PodReady {
+			return c.Status == api_v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func (e *K8sPool) excluded(pod *api_v1.Pod) bool {
+	if e.conf.ExcludeAnnotation == "" {
+		return false
+	}
+	exclude, _ := strconv.ParseBool(pod.Annotations[e.conf.ExcludeAnnotation])
+	return exclude
 }
 
+func (e *K8sPool) peersFromEndpointSlices() []string {
+	e.log.Debugf("Fetching peer list from endpointslices API")
+	var peers []string
+	for _, obj := range e.listStores(&e.sliceInformers) {
+		slice, ok := obj.(*discovery_v1.EndpointSlice)
+		if !ok {
+			e.log.Errorf("Skipping object: expected type v1.EndpointSlice got '%T' instead", obj)
+			e.eventError(EventErrorTypeAssert)
+			continue
+		}
+		if slice.AddressType == discovery_v1.AddressTypeFQDN {
+			e.log.Debugf("Skipping endpoint slice %s/%s with FQDN addresses", slice.Namespace, slice.Name)
+			continue
+		}
+
+		port := e.conf.PeerPort
+		if e.conf.AutoPort && len(slice.Ports) == 1 && slice.Ports[0].Port != nil {
+			port = int(*slice.Ports[0].Port)
+			e.log.Debugf("Using port %d of endpoint slice %s/%s", port, slice.Namespace, slice.Name)
+		}
+		for _, ep := range slice.Endpoints {
+			if len(ep.Addresses) == 0 {
+				continue
+			}
+			addr := ep.Addresses[0]
+			if !e.endpointReady(ep.Conditions) {
+				e.log.Debugf("Skipping address %s because it is not ready", addr)
+				continue
+			}
+			if e.conf.OnlyPodTargets && (ep.TargetRef == nil || ep.TargetRef.Kind != "Pod") {
+				e.log.Debugf("Skipping address %s because it is not backed by a pod", addr)
+				continue
+			}
+			if pod := e.podFor(ep.TargetRef); pod != nil && e.excluded(pod) {
+				e.log.Debugf("Skipping address %s because pod %s/%s is excluded by annotation", addr, pod.Namespace, pod.Name)
+				continue
+			}
+			host := addr
+			if e.conf.AddressMode == AddressHostname {
+				var hostname string
+				if ep.Hostname != nil {
+					hostname = *ep.Hostname
+				}
+				if hostname == "" && ep.TargetRef != nil {
+					hostname = ep.TargetRef.Name
+				}
+				service := slice.Labels[discovery_v1.LabelServiceName]
+				if hostname == "" || service == "" {
+					e.log.Debugf("Skipping address %s because it has no hostname", addr)
+					continue
+				}
+				host = e.serviceHostname(hostname, service, slice.Namespace)
+			}
+			peer := e.peerAddress(host, port)
+			e.weigh(peer, e.podFor(ep.TargetRef))
+
+			peers = append(peers, peer)
+			e.logWith("peer", peer).Debugf("Peer: %+v\n", peer)
+		}
+	}
+	return peers
+}
+
+// endpointReady interprets the endpoint slice conditions. A nil Ready
+// condition is to be treated as ready.
+func (e *K8sPool) endpointReady(c discovery_v1.EndpointConditions) bool {
+	if e.conf.ServingAsReady && c.Serving != nil {
+		return *c.Serving
+	}
+	return c.Ready == nil || *c.Ready
+}
+
+func (e *K8sPool) ownedBy(pod *api_v1.Pod) bool {
+	if e.conf.OwnerName == "" {
+		return true
+	}
+	for _, ref := range pod.OwnerReferences {
+		switch {
+		case e.conf.OwnerKind == "Deployment":
+			// The hash keeps web from matching the ReplicaSets of web-canary.
+			hash := pod.Labels["pod-template-hash"]
+			if ref.Kind == "ReplicaSet" && hash != "" && ref.Name == e.conf.OwnerName+"-"+hash {
+				return true
+			}
+		case e.conf.OwnerKind == "" || ref.Kind == e.conf.OwnerKind:
+			if ref.Name == e.conf.OwnerName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// podFor returns the pod referenced by an endpoint address if it is known to
+// the pool.
+func (e *K8sPool) podFor(ref *api_v1.ObjectReference) *api_v1.Pod {
+	if ref == nil || ref.Kind != "Pod" {
+		return nil
+	}
+	e.mu.Lock()
+	informers := e.podInformers
+	e.mu.Unlock()
+	for _, informer := range informers {
+		obj, exists, err := informer.GetStore().GetByKey(ref.Namespace + "/" + ref.Name)
+		if err != nil || !exists {
+			continue
+		}
+		if pod, ok := obj.(*api_v1.Pod); ok {
+			return pod
+		}
+	}
+	return nil
+}
+
+func (e *K8sPool) serviceHostname(hostname, service, namespace string) string {
+	return fmt.Sprintf("%s.%s.%s.svc.%s", hostname, service, namespace, e.conf.ClusterDomain)
+}
+
+func (e *K8sPool) setPeers(peers []string) {
+	peers = dedup(peers)
+	pending := e.pendingWeights
+	e.pendingWeights = nil
+	// The limit applies before verification, which would otherwise check
+	// every peer of a list that is rejected anyway.
+	if e.conf.MaxPeers > 0 && len(peers) > e.conf.MaxPeers {
+		err := fmt.Errorf("%w: found %d peers, limit is %d", ErrTooManyPeers, len(peers), e.conf.MaxPeers)
+		e.log.Errorf("Ignoring peer list: %s", err)
+		if e.conf.OnError != nil {
+			e.conf.OnError(err)
+		}
+		return
+	}
+	if e.conf.VerifyPeers {
+		peers = e.verifyPeers(peers)
+	}
+	e.mu.Lock()
+	if len(peers) == 0 && len(e.peers) > 0 && len(e.failing) > 0 && !e.conf.AllowEmptyPeers {
+		e.mu.Unlock()
+		e.log.Errorf("Ignoring empty peer list while watching fails, keeping %d last known peers", len(e.peers))
+		return
+	}
+	weights := make(map[string]int, len(peers))
+	for _, peer := range peers {
+		weights[peer] = 1
+		if w, ok := pending[peer]; ok {
+			weights[peer] = w
+		}
+	}
+	if e.conf.RemovalDelay > 0 {
+		peers = e.delayRemovals(peers)
+		for _, peer := range peers {
+			if _, ok := weights[peer]; !ok {
+				weights[peer] = e.weights[peer]
+			}
+		}
+	}
+	added, removed := diff(e.peers, peers)
+	// Resyncs recompute unchanged peers, only the first list and changes of
+	// the peers or their weights are updates.
+	changed := len(added) > 0 || len(removed) > 0 || e.lastUpdate.IsZero() || !sameWeights(e.weights, weights)
+	e.peers = peers
+	e.weights = weights
+	if changed {
+		if !e.conf.DryRun && (len(added) > 0 || len(removed) > 0) {
+			e.publish(peers)
+		}
+		close(e.updated)
+		e.updated = make(chan struct{})
+		e.lastUpdate = time.Now()
+		e.updateCount++
+	}
+	e.mu.Unlock()
+	if e.conf.DryRun {
+		if len(added) > 0 || len(removed) > 0 {
+			e.infof("Dry run: peers %v (added %v, removed %v)", peers, added, removed)
+		}
+		return
+	}
+	if changed && e.conf.OnUpdate != nil {
+		e.conf.OnUpdate(peers)
+	}
+	// A rejected update is retried even if the peers did not change since.
+	if e.conf.OnUpdateErr != nil && (changed || e.updateRetries > 0) {
+		e.tryUpdate(peers)
+	}
+	if changed && e.conf.OnWeightedUpdate != nil {
+		e.conf.OnWeightedUpdate(copyWeights(weights))
+	}
+	if e.conf.OnChange != nil && (len(added) > 0 || len(removed) > 0) {
+		e.conf.OnChange(added, removed)
+	}
+}
+
+// tryUpdate calls OnUpdateErr and schedules a resync with backoff if it
+// fails. It must only be called from the update function.
+func (e *K8sPool) tryUpdate(peers []string) {
+	err := e.conf.OnUpdateErr(peers)
+	if err == nil {
+		e.updateRetries = 0
+		return
+	}
+	backoff := maxUpdateRetryBackoff
+	if e.updateRetries < 16 && e.conf.UpdateRetryBackoff<<e.updateRetries < maxUpdateRetryBackoff {
+		backoff = e.conf.UpdateRetryBackoff << e.updateRetries
+	}
+	e.updateRetries++
+	e.log.Errorf("Failed to update peers, retrying in %s: %s", backoff, err)
+	if e.conf.OnError != nil {
+		e.conf.OnError(err)
+	}
+	if e.retryTimer == nil {
+		e.retryTimer = time.AfterFunc(backoff, func() {
+			if e.watchCtx.Err() == nil {
+				e.Sync()
+			}
+		})
+	} else {
+		e.retryTimer.Reset(backoff)
+	}
+}
+
+const maxUpdateRetryBackoff = time.Minute
+
+// delayRemovals keeps peers missing from peers in the list until RemovalDelay
+// has passed. A pending removal is cancelled if the peer reappears. e.mu must
+// be held.
+func (e *K8sPool) delayRemovals(peers []string) []string {
+	if e.removing == nil {
+		e.removing = map[string]*time.Timer{}
+	}
+	current := make(map[string]bool, len(peers))
+	for _, peer := range peers {
+		current[peer] = true
+		if t, ok := e.removing[peer]; ok {
+			if t != nil {
+				t.Stop()
+			}
+			delete(e.removing, peer)
+		}
+	}
+	var keep []string
+	for _, peer := range e.peers {
+		if current[peer] {
+			continue
+		}
+		t, ok := e.removing[peer]
+		switch {
+		case !ok:
+			e.log.Debugf("Removing peer %s in %s", peer, e.conf.RemovalDelay)
+			peer := peer
+			var t *time.Timer
+			t = time.AfterFunc(e.conf.RemovalDelay, func() {
+				e.mu.Lock()
+				if e.removing[peer] == t {
+					e.removing[peer] = nil
+				}
+				e.mu.Unlock()
+				if e.watchCtx.Err() == nil {
+					e.Sync()
+				}
+			})
+			e.removing[peer] = t
+			keep = append(keep, peer)
+		case t != nil:
+			keep = append(keep, peer)
+		default:
+			delete(e.removing, peer)
+		}
+	}
+	return dedup(append(peers, keep...))
+}
+
+// updatesBuffer is the number of peer lists buffered for a subscriber.
+const updatesBuffer = 4
+
+// Updates returns a channel receiving the peer list on every change,
+// starting with the current one if there is one. Each call returns a new
+// channel. If the receiver does not keep up the oldest buffered list is
+// dropped. The channel is closed when the pool is closed.
+func (e *K8sPool) Updates() <-chan []string {
+	ch := make(chan []string, updatesBuffer)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		close(ch)
+		return ch
+	}
+	if !e.lastUpdate.IsZero() {
+		ch <- append([]string(nil), e.peers...)
+	}
+	e.subscribers = append(e.subscribers, ch)
+	return ch
+}
+
+// publish sends peers to all subscribers without blocking. e.mu must be held.
+func (e *K8sPool) publish(peers []string) {
+	for _, ch := range e.subscribers {
+		select {
+		case ch <- append([]string(nil), peers...):
+			continue
+		default:
+		}
+		// Full, drop the oldest list. There is only one sender, so the
+		// following send can not block.
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- append([]string(nil), peers...)
+	}
+}
+
+func copyWeights(weights map[string]int) map[string]int {
+	c := make(map[string]int, len(weights))
+	for peer, w := range weights {
+		c[peer] = w
+	}
+	return c
+}
+
+func sameWeights(a, b map[string]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for peer, w := range a {
+		if b[peer] != w {
+			return false
+		}
+	}
+	return true
+}
+
+// dedup returns the sorted list of unique peers.
+func dedup(peers []string) []string {
+	sort.Strings(peers)
+	result := peers[:0]
+	for i, p := range peers {
+		if i == 0 || p != peers[i-1] {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// diff returns the elements added and removed between the sorted lists old and new.
+func diff(old, new []string) (added, removed []string) {
+	i, j := 0, 0
+	for i < len(old) || j < len(new) {
+		switch {
+		case j == len(new) || (i < len(old) && old[i] < new[j]):
+			removed = append(removed, old[i])
+			i++
+		case i == len(old) || new[j] < old[i]:
+			added = append(added, new[j])
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	return added, removed
+}
+
+// scheduleSync calls Sync, debounced if configured.
+func (e *K8sPool) scheduleSync() {
+	if e.conf.Debounce <= 0 {
+		e.Sync()
+		return
+	}
+	delay := e.conf.Debounce + time.Duration(rand.Int63n(int64(e.conf.Debounce)/10+1))
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.syncTimer == nil {
+		e.syncTimer = time.AfterFunc(delay, func() {
+			if e.watchCtx.Err() == nil {
+				e.Sync()
+			}
+		})
+		return
+	}
+	e.syncTimer.Reset(delay)
+}
+
+// Sync recomputes the peer list from the current informer store and calls
+// OnUpdate. It may be called before the initial sync has completed.
+func (e *K8sPool) Sync() {
+	e.updateMu.Lock()
+	defer e.updateMu.Unlock()
+	if e.update != nil {
+		e.update()
+	}
+	e.mu.Lock()
+	e.lastSync = time.Now()
+	e.mu.Unlock()
+}
+
+// HasSynced returns true once all informers have completed their initial sync.
+func (e *K8sPool) HasSynced() bool {
+	if e.conf.Mechanism == WatchStatic {
+		return true
+	}
+	e.mu.Lock()
+	informers := e.informers()
+	e.mu.Unlock()
+	if len(informers) == 0 {
+		return false
+	}
+	// Polled informers are only installed after their first poll.
+	if e.conf.PollInterval > 0 {
+		return true
+	}
+	for _, informer := range informers {
+		if !informer.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// Peers returns the peer list last passed to OnUpdate.
+func (e *K8sPool) Peers() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.peers...)
+}
+
+// WaitForPeers blocks until at least min peers are known or the timeout
+// expires.
+func (e *K8sPool) WaitForPeers(min int, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		e.mu.Lock()
+		n, updated := len(e.peers), e.updated
+		e.mu.Unlock()
+		if n >= min {
+			return nil
+		}
+		select {
+		case <-updated:
+		case <-timer.C:
+			return fmt.Errorf("timed out waiting for %d peers, got %d", min, n)
+		case <-e.done:
+			return fmt.Errorf("pool closed while waiting for peers")
+		}
+	}
+}
+
+// Weights returns the weight of each peer in the current peer list.
+func (e *K8sPool) Weights() map[string]int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return copyWeights(e.weights)
+}
+
+// LastUpdate returns the time OnUpdate was last called, i.e. when the peers
+// or their weights last changed.
+func (e *K8sPool) LastUpdate() time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.lastUpdate
+}
+
+// UpdateCount returns the number of times OnUpdate has been called.
+func (e *K8sPool) UpdateCount() uint64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.updateCount
+}
+
+// SetSelector replaces the label selector (and any Selectors). New informers
+// are started with the new selector and replace the running ones once they
+// synced, after which the peers are re-emitted. If they fail to sync the
+// running informers are kept and an error is returned.
+func (e *K8sPool) SetSelector(sel string) error {
+	if _, err := labels.Parse(sel); err != nil {
+		return fmt.Errorf("Failed to parse selector %q: %w", sel, err)
+	}
+	return e.restart(func(conf *Config) {
+		conf.Selector = sel
+		conf.Selectors = nil
+	})
+}
+
+// SetNamespace replaces the watched namespace like SetSelector, an empty
+// namespace watches all namespaces. The namespace is listed once before
+// starting the new informers, so a namespace the pool may not access is
+// rejected right away.
+func (e *K8sPool) SetNamespace(ns string) error {
+	if ns != "" {
+		if errs := validation.IsDNS1123Label(ns); len(errs) > 0 {
+			return fmt.Errorf("Invalid namespace %q: %s", ns, strings.Join(errs, ", "))
+		}
+	}
+	return e.restart(func(conf *Config) {
+		conf.Namespace = ns
+	})
+}
+
+// restart starts new informers for the config modified by change, which
+// replace the running informers once synced.
+func (e *K8sPool) restart(change func(conf *Config)) error {
+	e.reloadMu.Lock()
+	defer e.reloadMu.Unlock()
+
+	e.mu.Lock()
+	conf := e.conf
+	e.mu.Unlock()
+	change(&conf)
+
+	if err := e.probeList(&conf); err != nil {
+		return fmt.Errorf("Failed to list peers: %w", err)
+	}
+	if err := e.start(conf); err != nil {
+		return fmt.Errorf("Failed to restart watch: %w", err)
+	}
+	return nil
+}
+
+// probeList lists the resources watched with conf once.
+func (e *K8sPool) probeList(conf *Config) error {
+	var listWatches []func(conf *Config, selector string) *cache.ListWatch
+	switch conf.Mechanism {
+	case "", WatchEndpoints:
+		listWatches = append(listWatches, e.endpointListWatch)
+	case WatchPods:
+		listWatches = append(listWatches, e.podListWatch)
+	case WatchAuto:
+		listWatches = append(listWatches, e.endpointListWatch, e.podListWatch)
+	case WatchEndpointSlices:
+		listWatches = append(listWatches, e.endpointSliceListWatch)
+	}
+	for _, listWatch := range listWatches {
+		if _, err := listWatch(conf, conf.Selector).List(meta_v1.ListOptions{Limit: 1}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reload replaces the peers of a static pool and re-emits them.
+func (e *K8sPool) Reload(peers []string) error {
+	if e.conf.Mechanism != WatchStatic {
+		return fmt.Errorf("Reload is only supported by the %s mechanism", WatchStatic)
+	}
+	e.mu.Lock()
+	e.conf.StaticPeers = append([]string(nil), peers...)
+	e.mu.Unlock()
+	e.Sync()
+	return nil
+}
+
+const (
+	EventErrorKeyFunc    = "keyfunc"
+	EventErrorTypeAssert = "type_assert"
+)
+
+func (e *K8sPool) eventError(reason string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.eventErrors == nil {
+		e.eventErrors = map[string]uint64{}
+	}
+	e.eventErrors[reason]++
+}
+
+// EventErrors returns the number of informer events that could not be
+// processed, by reason.
+func (e *K8sPool) EventErrors() map[string]uint64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	errs := map[string]uint64{EventErrorKeyFunc: 0, EventErrorTypeAssert: 0}
+	for reason, n := range e.eventErrors {
+		errs[reason] = n
+	}
+	return errs
+}
+
+// Namespace returns the watched namespace, which is empty when watching all
+// namespaces.
+func (e *K8sPool) Namespace() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.conf.Namespace
+}
+
+// LastSync returns when the peers were last recomputed from the informer
+// stores, i.e. when the last event was processed.
+func (e *K8sPool) LastSync() time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.lastSync
+}
+
+// WatchErrors returns the number of failed watches since start.
+func (e *K8sPool) WatchErrors() uint64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.watchErrors
+}
+
+// LastResourceVersions returns the resource version last seen by each
+// informer. A version that stops moving indicates a stuck watch.
+func (e *K8sPool) LastResourceVersions() []string {
+	e.mu.Lock()
+	informers := e.informers()
+	e.mu.Unlock()
+	versions := make([]string, len(informers))
+	for i, informer := range informers {
+		versions[i] = informer.LastSyncResourceVersion()
+	}
+	return versions
+}
+
+// Alive reports whether the pool is still watching for peers, i.e. it has
+// not been closed and its context has not been cancelled.
+func (e *K8sPool) Alive() bool {
+	return e.watchCtx.Err() == nil
+}
+
+// Close stops watching for peers and waits until the informers stopped. It
+// must not be called from the pool's callbacks.
 func (e *K8sPool) Close() {
+	e.CloseWithContext(context.Background())
+}
+
+// CloseWithContext is like Close but gives up waiting for the informers to
+// stop once ctx is done, returning its error.
+func (e *K8sPool) CloseWithContext(ctx context.Context) error {
+	e.mu.Lock()
+	e.closed = true
+	e.mu.Unlock()
 	e.watchCancel()
-	close(e.done)
+	stopped := make(chan struct{})
+	go func() {
+		e.running.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
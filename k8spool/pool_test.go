@@ -0,0 +1,288 @@
+package k8spool
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	api_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestNormalizeScheme(t *testing.T) {
+	tests := map[string]string{
+		"":         "",
+		"http":     "http",
+		"HTTP":     "http",
+		" Https ":  "https",
+		"https://": "https",
+		"ftp":      "ftp",
+	}
+	for in, want := range tests {
+		if got := normalizeScheme(in); got != want {
+			t.Errorf("normalizeScheme(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    Config
+		wantErr error
+	}{
+		{name: "zero", conf: Config{}},
+		{name: "upper case scheme", conf: Config{PeerScheme: "HTTPS"}},
+		{name: "scheme with separator", conf: Config{PeerScheme: "http://"}},
+		{name: "invalid scheme", conf: Config{PeerScheme: "ftp"}, wantErr: ErrInvalidConfig},
+		{name: "port", conf: Config{PeerPort: 65535}},
+		{name: "negative port", conf: Config{PeerPort: -1}, wantErr: ErrInvalidConfig},
+		{name: "port out of range", conf: Config{PeerPort: 65536}, wantErr: ErrInvalidConfig},
+		{name: "mechanism", conf: Config{Mechanism: WatchEndpointSlices}},
+		{name: "unknown mechanism", conf: Config{Mechanism: "services"}, wantErr: ErrUnknownMechanism},
+		{name: "unknown address mode", conf: Config{AddressMode: "mac"}, wantErr: ErrInvalidConfig},
+		{name: "negative max peers", conf: Config{MaxPeers: -1}, wantErr: ErrInvalidConfig},
+		{name: "weights from pods", conf: Config{Mechanism: WatchAuto, WeightAnnotation: DefaultWeightAnnotation}},
+		{name: "weights from endpoints", conf: Config{WeightAnnotation: DefaultWeightAnnotation}, wantErr: ErrInvalidConfig},
+	}
+	for _, tt := range tests {
+		err := tt.conf.Validate()
+		if tt.wantErr == nil {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %s", tt.name, err)
+			}
+			continue
+		}
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: got error %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%s: error %v does not wrap ErrInvalidConfig", tt.name, err)
+		}
+	}
+}
+
+func TestPeerURL(t *testing.T) {
+	tests := []struct {
+		scheme, host string
+		port         int
+		path         string
+		want         string
+	}{
+		{"http", "10.0.0.1", 8080, "", "http://10.0.0.1:8080"},
+		{"https", "10.0.0.1", 443, "", "https://10.0.0.1:443"},
+		{"http", "10.0.0.1", 8080, "/_groupcache/", "http://10.0.0.1:8080/_groupcache/"},
+		{"http", "fd00::1", 8080, "", "http://[fd00::1]:8080"},
+		{"http", "fd00::1", 8080, "/health/ready", "http://[fd00::1]:8080/health/ready"},
+		{"http", "::1", 80, "", "http://[::1]:80"},
+		{"http", "pod-0.svc.ns.svc.cluster.local", 8080, "", "http://pod-0.svc.ns.svc.cluster.local:8080"},
+	}
+	for _, tt := range tests {
+		if got := PeerURL(tt.scheme, tt.host, tt.port, tt.path); got != tt.want {
+			t.Errorf("PeerURL(%q, %q, %d, %q) = %q, want %q", tt.scheme, tt.host, tt.port, tt.path, got, tt.want)
+		}
+	}
+}
+
+// newTestPool returns a pool that is not watching, whose informer stores are
+// filled by the test.
+func newTestPool(conf Config) *K8sPool {
+	conf.PeerScheme = "http"
+	conf.PeerPort = 8080
+	ctx, cancel := context.WithCancel(context.Background())
+	return &K8sPool{
+		log:         StdLogger{},
+		conf:        conf,
+		watchCtx:    ctx,
+		watchCancel: cancel,
+		done:        make(chan struct{}),
+		updated:     make(chan struct{}),
+	}
+}
+
+func newTestInformer(objType runtime.Object, objs ...interface{}) cache.SharedIndexInformer {
+	informer := cache.NewSharedIndexInformer(&cache.ListWatch{}, objType, 0, cache.Indexers{})
+	for _, obj := range objs {
+		informer.GetStore().Add(obj)
+	}
+	return informer
+}
+
+func readyPod(name, ip string) *api_v1.Pod {
+	return &api_v1.Pod{
+		ObjectMeta: meta_v1.ObjectMeta{Namespace: "default", Name: name},
+		Status: api_v1.PodStatus{
+			Phase: api_v1.PodRunning,
+			PodIP: ip,
+			ContainerStatuses: []api_v1.ContainerStatus{
+				{Name: "cache", Ready: true, State: api_v1.ContainerState{Running: &api_v1.ContainerStateRunning{}}},
+			},
+		},
+	}
+}
+
+func TestForeignObjectsInStore(t *testing.T) {
+	foreign := &api_v1.Service{ObjectMeta: meta_v1.ObjectMeta{Namespace: "default", Name: "foreign"}}
+
+	pool := newTestPool(Config{})
+	pool.podInformers = []cache.SharedIndexInformer{newTestInformer(&api_v1.Pod{}, readyPod("pod-0", "10.0.0.1"), foreign)}
+	if got, want := pool.peersFromPods(nil), []string{"http://10.0.0.1:8080"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got pod peers %v, want %v", got, want)
+	}
+	if n := pool.EventErrors()[EventErrorTypeAssert]; n != 1 {
+		t.Errorf("got %d type assertion errors for pods, want 1", n)
+	}
+
+	endpoints := &api_v1.Endpoints{
+		ObjectMeta: meta_v1.ObjectMeta{Namespace: "default", Name: "cache"},
+		Subsets:    []api_v1.EndpointSubset{{Addresses: []api_v1.EndpointAddress{{IP: "10.0.0.2"}}}},
+	}
+	pool = newTestPool(Config{})
+	pool.endpointInformers = []cache.SharedIndexInformer{newTestInformer(&api_v1.Endpoints{}, endpoints, foreign)}
+	got, _ := pool.peersFromEndpoints()
+	if want := []string{"http://10.0.0.2:8080"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got endpoint peers %v, want %v", got, want)
+	}
+	if n := pool.EventErrors()[EventErrorTypeAssert]; n != 1 {
+		t.Errorf("got %d type assertion errors for endpoints, want 1", n)
+	}
+}
+
+func TestPeersAutoSkipsPodsInEndpoints(t *testing.T) {
+	pod0 := readyPod("pod-0", "10.0.0.1")
+	pod0.Annotations = map[string]string{DefaultPortAnnotation: "9090"}
+	pod1 := readyPod("pod-1", "10.0.0.2")
+	endpoints := &api_v1.Endpoints{
+		ObjectMeta: meta_v1.ObjectMeta{Namespace: "default", Name: "cache"},
+		Subsets: []api_v1.EndpointSubset{{Addresses: []api_v1.EndpointAddress{
+			{IP: "10.0.0.1", TargetRef: &api_v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "pod-0"}},
+		}}},
+	}
+
+	var got []string
+	pool := newTestPool(Config{PortAnnotation: DefaultPortAnnotation, OnUpdate: func(peers []string) { got = peers }})
+	pool.endpointInformers = []cache.SharedIndexInformer{newTestInformer(&api_v1.Endpoints{}, endpoints)}
+	pool.podInformers = []cache.SharedIndexInformer{newTestInformer(&api_v1.Pod{}, pod0, pod1)}
+	pool.update = pool.updatePeersAuto
+	pool.Sync()
+
+	if want := []string{"http://10.0.0.1:8080", "http://10.0.0.2:8080"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got peers %v, want %v", got, want)
+	}
+}
+
+func TestEmptyPeersWhileWatchFails(t *testing.T) {
+	var got []string
+	pool := newTestPool(Config{Mechanism: WatchPods, OnUpdate: func(peers []string) { got = peers }})
+	informer := newTestInformer(&api_v1.Pod{}, readyPod("pod-0", "10.0.0.1"))
+	pool.podInformers = []cache.SharedIndexInformer{informer}
+	pool.update = pool.updatePeersFromPods
+	pool.Sync()
+	if want := []string{"http://10.0.0.1:8080"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got peers %v, want %v", got, want)
+	}
+
+	pool.setFailing(informer, true)
+	informer.GetStore().Replace(nil, "")
+	pool.Sync()
+	if want := []string{"http://10.0.0.1:8080"}; !reflect.DeepEqual(pool.Peers(), want) {
+		t.Errorf("got peers %v while the watch fails, want %v", pool.Peers(), want)
+	}
+
+	// A successful relist clears the failure, so the empty list is genuine.
+	pool.setFailing(informer, false)
+	pool.Sync()
+	if len(pool.Peers()) != 0 {
+		t.Errorf("got peers %v after a successful list, want none", pool.Peers())
+	}
+}
+
+func TestUpdateOnlyOnChange(t *testing.T) {
+	var updates int
+	pool := newTestPool(Config{Mechanism: WatchPods, WeightAnnotation: DefaultWeightAnnotation, OnUpdate: func([]string) { updates++ }})
+	pod := readyPod("pod-0", "10.0.0.1")
+	informer := newTestInformer(&api_v1.Pod{}, pod)
+	pool.podInformers = []cache.SharedIndexInformer{informer}
+	pool.update = pool.updatePeersFromPods
+	pool.Sync()
+	pool.Sync()
+	if updates != 1 || pool.UpdateCount() != 1 {
+		t.Errorf("got %d updates and count %d for unchanged peers, want 1", updates, pool.UpdateCount())
+	}
+
+	weighted := pod.DeepCopy()
+	weighted.Annotations = map[string]string{DefaultWeightAnnotation: "2"}
+	informer.GetStore().Update(weighted)
+	pool.Sync()
+	informer.GetStore().Add(readyPod("pod-1", "10.0.0.2"))
+	pool.Sync()
+	pool.Sync()
+	if updates != 3 || pool.UpdateCount() != 3 {
+		t.Errorf("got %d updates and count %d after a weight and a peer change, want 3", updates, pool.UpdateCount())
+	}
+}
+
+func TestMaxPeersBeforeVerify(t *testing.T) {
+	var errs []error
+	pool := newTestPool(Config{MaxPeers: 1, VerifyPeers: true, OnError: func(err error) { errs = append(errs, err) }})
+	defer pool.watchCancel()
+	pool.setPeers([]string{"http://10.0.0.1:8080", "http://10.0.0.2:8080"})
+	if len(errs) != 1 || !errors.Is(errs[0], ErrTooManyPeers) {
+		t.Errorf("got errors %v, want %s", errs, ErrTooManyPeers)
+	}
+	pool.verifyMu.Lock()
+	defer pool.verifyMu.Unlock()
+	if len(pool.verifying) != 0 {
+		t.Errorf("verifying %v of a rejected list", pool.verifying)
+	}
+}
+
+func TestNoGoroutinesAfterClose(t *testing.T) {
+	pool := newTestPool(Config{})
+	pool.Close()
+	if pool.goRunning(func() {}) {
+		t.Error("goroutine started after Close")
+	}
+	if _, err := pool.runInformers([]cache.SharedIndexInformer{newTestInformer(&api_v1.Pod{})}); err == nil {
+		t.Error("informers started after Close")
+	}
+}
+
+func TestServiceInformers(t *testing.T) {
+	pool := newTestPool(Config{Selectors: []string{"app=web", "app=api"}})
+	if n := len(pool.newServiceInformers(&pool.conf, &api_v1.Endpoints{}, pool.endpointListWatch)); n != 2 {
+		t.Errorf("got %d informers for two selectors, want 2", n)
+	}
+	pool.conf.ServiceName = "web"
+	if n := len(pool.newServiceInformers(&pool.conf, &api_v1.Endpoints{}, pool.endpointListWatch)); n != 1 {
+		t.Errorf("got %d informers for a service, want 1", n)
+	}
+}
+
+func TestOwnedByDeployment(t *testing.T) {
+	pod := func(replicaSet, hash string) *api_v1.Pod {
+		return &api_v1.Pod{ObjectMeta: meta_v1.ObjectMeta{
+			Labels:          map[string]string{"pod-template-hash": hash},
+			OwnerReferences: []meta_v1.OwnerReference{{Kind: "ReplicaSet", Name: replicaSet}},
+		}}
+	}
+	pool := newTestPool(Config{OwnerKind: "Deployment", OwnerName: "web"})
+	tests := []struct {
+		name string
+		pod  *api_v1.Pod
+		want bool
+	}{
+		{"own replica set", pod("web-5d8f7c9b4", "5d8f7c9b4"), true},
+		{"canary replica set", pod("web-canary-6c4b8d7f5", "6c4b8d7f5"), false},
+		{"no hash label", pod("web-5d8f7c9b4", ""), false},
+		{"other deployment", pod("api-5d8f7c9b4", "5d8f7c9b4"), false},
+	}
+	for _, tt := range tests {
+		if got := pool.ownedBy(tt.pod); got != tt.want {
+			t.Errorf("%s: got owned %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package k8spool
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ReadinessOptions configures ReadinessHandler.
+//
+// With WatchEndpoints and WatchEndpointSlices the peers are the ready
+// addresses of a service, which include a pod only once its readiness probe
+// passed. A probe requiring Self therefore never passes, and one requiring
+// MinPeers waits for that many other pods to be ready, which never happens
+// if all pods start together, unless the service sets
+// publishNotReadyAddresses.
+type ReadinessOptions struct {
+	// MinPeers is the number of peers required to be ready. With the
+	// endpoints mechanisms the pod itself does not count, see above.
+	MinPeers int
+	// Self is the local peer, which is required to be in the peer list if
+	// set. With the endpoints mechanisms it requires the service to set
+	// publishNotReadyAddresses, see above.
+	Self string
+}
+
+// ReadinessHandler returns a readiness probe handler responding with 200 once
+// pool synced, is still watching and has at least MinPeers peers including
+// Self. Otherwise it responds with 503 and a JSON body naming the failed
+// condition.
+func ReadinessHandler(pool Pool, opts ReadinessOptions) http.HandlerFunc {
+	return func(rw http.ResponseWriter, _ *http.Request) {
+		type status struct {
+			Ready   bool   `json:"ready"`
+			Reason  string `json:"reason,omitempty"`
+			Message string `json:"message,omitempty"`
+		}
+		result := status{Ready: true}
+		peers := pool.Peers()
+		switch {
+		case !pool.Alive():
+			result = status{Reason: "not_watching", Message: "peer watcher stopped"}
+		case !pool.HasSynced():
+			result = status{Reason: "not_synced", Message: "peer watcher not synced"}
+		case len(peers) < opts.MinPeers:
+			result = status{Reason: "min_peers", Message: fmt.Sprintf("waiting for peers: %d/%d", len(peers), opts.MinPeers)}
+		case opts.Self != "" && !contains(peers, opts.Self):
+			result = status{Reason: "self_missing", Message: fmt.Sprintf("%s is not in the peer list", opts.Self)}
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		if !result.Ready {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(rw).Encode(result)
+	}
+}
@@ -0,0 +1,95 @@
+package k8spool_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/databus23/k8sgroupcache/k8spool"
+	"github.com/databus23/k8sgroupcache/k8spool/fake"
+)
+
+func TestReadinessHandler(t *testing.T) {
+	const self = "http://10.0.0.1:8080"
+	tests := []struct {
+		name   string
+		pool   func() *fake.Pool
+		opts   k8spool.ReadinessOptions
+		status int
+		reason string
+	}{
+		{
+			name: "not synced",
+			pool: func() *fake.Pool {
+				pool := fake.New(self)
+				pool.SetSynced(false)
+				return pool
+			},
+			status: http.StatusServiceUnavailable,
+			reason: "not_synced",
+		},
+		{
+			name:   "no peers",
+			pool:   func() *fake.Pool { return fake.New() },
+			opts:   k8spool.ReadinessOptions{MinPeers: 1},
+			status: http.StatusServiceUnavailable,
+			reason: "min_peers",
+		},
+		{
+			name:   "too few peers",
+			pool:   func() *fake.Pool { return fake.New(self) },
+			opts:   k8spool.ReadinessOptions{MinPeers: 2},
+			status: http.StatusServiceUnavailable,
+			reason: "min_peers",
+		},
+		{
+			name:   "self missing",
+			pool:   func() *fake.Pool { return fake.New("http://10.0.0.2:8080") },
+			opts:   k8spool.ReadinessOptions{Self: self},
+			status: http.StatusServiceUnavailable,
+			reason: "self_missing",
+		},
+		{
+			name: "watch stopped",
+			pool: func() *fake.Pool {
+				pool := fake.New(self)
+				pool.Close()
+				return pool
+			},
+			status: http.StatusServiceUnavailable,
+			reason: "not_watching",
+		},
+		{
+			name:   "ready",
+			pool:   func() *fake.Pool { return fake.New(self, "http://10.0.0.2:8080") },
+			opts:   k8spool.ReadinessOptions{MinPeers: 2, Self: self},
+			status: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			k8spool.ReadinessHandler(tt.pool(), tt.opts)(rec, httptest.NewRequest("GET", "/health/ready", nil))
+			if rec.Code != tt.status {
+				t.Errorf("got status %d, want %d", rec.Code, tt.status)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("got content type %q, want application/json", ct)
+			}
+			var body struct {
+				Ready  bool   `json:"ready"`
+				Reason string `json:"reason"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("Failed to decode body: %s", err)
+			}
+			if body.Ready != (tt.status == http.StatusOK) {
+				t.Errorf("got ready %t for status %d", body.Ready, rec.Code)
+			}
+			if body.Reason != tt.reason {
+				t.Errorf("got reason %q, want %q", body.Reason, tt.reason)
+			}
+		})
+	}
+}
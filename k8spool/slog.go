@@ -0,0 +1,70 @@
+package k8spool
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// FieldLogger is optionally implemented by a Logger that supports structured
+// key/value fields. K8sPool attaches fields like key, peer and event to its
+// messages when the configured Logger implements it.
+type FieldLogger interface {
+	Logger
+	With(args ...any) Logger
+}
+
+// InfoLogger is optionally implemented by a Logger that supports an info
+// level. Loggers without it get info messages at error level.
+type InfoLogger interface {
+	Infof(format string, v ...any)
+}
+
+// SlogLogger adapts a *slog.Logger to the Logger interface.
+type SlogLogger struct {
+	logger *slog.Logger
+}
+
+func NewSlogLogger(logger *slog.Logger) Logger {
+	return &SlogLogger{logger: logger}
+}
+
+func (l *SlogLogger) Debugf(format string, v ...any) {
+	l.log(slog.LevelDebug, format, v...)
+}
+
+func (l *SlogLogger) Infof(format string, v ...any) {
+	l.log(slog.LevelInfo, format, v...)
+}
+
+func (l *SlogLogger) Errorf(format string, v ...any) {
+	l.log(slog.LevelError, format, v...)
+}
+
+func (l *SlogLogger) With(args ...any) Logger {
+	return &SlogLogger{logger: l.logger.With(args...)}
+}
+
+func (l *SlogLogger) log(level slog.Level, format string, v ...any) {
+	if !l.logger.Enabled(context.Background(), level) {
+		return
+	}
+	l.logger.Log(context.Background(), level, fmt.Sprintf(format, v...))
+}
+
+// logWith returns the pool's logger with the given fields attached, if the
+// logger supports structured fields.
+func (e *K8sPool) logWith(args ...any) Logger {
+	if l, ok := e.log.(FieldLogger); ok {
+		return l.With(args...)
+	}
+	return e.log
+}
+
+func (e *K8sPool) infof(format string, v ...any) {
+	if l, ok := e.log.(InfoLogger); ok {
+		l.Infof(format, v...)
+		return
+	}
+	e.log.Errorf(format, v...)
+}
@@ -0,0 +1,143 @@
+package k8spool
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type verifyResult struct {
+	ok bool
+	at time.Time
+}
+
+// verifyPeers returns the peers whose last check with a GET on VerifyPath
+// answered with a 2xx status. Peers without a result younger than
+// VerifyCacheTTL are checked in the background, bounded by VerifyTimeout, and
+// the peers are recomputed once the checks finished. Until then new peers
+// are left out while known ones keep their last result. If peers are dropped
+// a resync is scheduled so they are checked again once the cache expired.
+func (e *K8sPool) verifyPeers(peers []string) []string {
+	now := time.Now()
+	var verified, check []string
+	e.verifyMu.Lock()
+	for peer := range e.verified {
+		if !contains(peers, peer) {
+			delete(e.verified, peer)
+		}
+	}
+	for _, peer := range peers {
+		result, ok := e.verified[peer]
+		if ok && result.ok {
+			verified = append(verified, peer)
+		} else {
+			e.logWith("peer", peer).Debugf("Skipping peer %s until it passed verification", peer)
+		}
+		if (!ok || now.Sub(result.at) >= e.conf.VerifyCacheTTL) && !e.verifying[peer] {
+			if e.verifying == nil {
+				e.verifying = map[string]bool{}
+			}
+			e.verifying[peer] = true
+			check = append(check, peer)
+		}
+	}
+	e.verifyMu.Unlock()
+
+	if len(check) > 0 && e.watchCtx.Err() == nil {
+		e.goRunning(func() { e.checkPeers(check) })
+	}
+	if len(verified) < len(peers) {
+		e.verifyMu.Lock()
+		if e.verifyTimer == nil {
+			e.verifyTimer = time.AfterFunc(e.conf.VerifyCacheTTL, func() {
+				if e.watchCtx.Err() == nil {
+					e.scheduleSync()
+				}
+			})
+		} else {
+			e.verifyTimer.Reset(e.conf.VerifyCacheTTL)
+		}
+		e.verifyMu.Unlock()
+	}
+	return verified
+}
+
+// checkPeers verifies peers and recomputes the peers with the results.
+func (e *K8sPool) checkPeers(peers []string) {
+	ctx, cancel := context.WithTimeout(e.watchCtx, e.conf.VerifyTimeout)
+	defer cancel()
+	sem := make(chan struct{}, e.conf.VerifyConcurrency)
+	var wg sync.WaitGroup
+	for _, peer := range peers {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(peer string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			e.verifyPeer(ctx, peer)
+		}(peer)
+	}
+	wg.Wait()
+
+	e.verifyMu.Lock()
+	for _, peer := range peers {
+		delete(e.verifying, peer)
+	}
+	e.verifyMu.Unlock()
+	if e.watchCtx.Err() == nil {
+		e.scheduleSync()
+	}
+}
+
+func (e *K8sPool) verifyPeer(ctx context.Context, peer string) {
+	ok := false
+	target := peer
+	if e.conf.AddressFormat == FormatHostPort {
+		target = e.conf.PeerScheme + "://" + peer
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, verifyURL(target, e.conf.VerifyPath), nil)
+	if err == nil {
+		var resp *http.Response
+		if resp, err = e.conf.VerifyClient.Do(req); err == nil {
+			resp.Body.Close()
+			ok = resp.StatusCode >= 200 && resp.StatusCode < 300
+		}
+	}
+	if err != nil {
+		e.logWith("peer", peer).Debugf("Failed to verify peer %s: %s", peer, err)
+	}
+
+	e.verifyMu.Lock()
+	if e.verified == nil {
+		e.verified = map[string]verifyResult{}
+	}
+	e.verified[peer] = verifyResult{ok: ok, at: time.Now()}
+	e.verifyMu.Unlock()
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyURL returns the URL of path on peer. Peers not created by PeerURL,
+// e.g. static ones, get path appended as is.
+func verifyURL(peer, path string) string {
+	u, err := url.Parse(peer)
+	if err != nil {
+		return strings.TrimSuffix(peer, "/") + path
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		return strings.TrimSuffix(peer, "/") + path
+	}
+	return PeerURL(u.Scheme, u.Hostname(), port, path)
+}
@@ -2,28 +2,101 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/http/pprof"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/databus23/k8sgroupcache/cache"
+	"github.com/databus23/k8sgroupcache/getter"
 	"github.com/databus23/k8sgroupcache/k8spool"
 	"github.com/databus23/k8sgroupcache/metrics"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/mailgun/groupcache/v2"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
+// Set via -ldflags "-X main.version=... -X main.commit=...".
 var (
-	selfip    string
-	namespace string
-	selector  string
-	port      int
+	version string
+	commit  string
+)
+
+var (
+	selfip          string
+	namespace       string
+	selector        string
+	port            int
+	shutdownTimeout time.Duration
+	groupName       string
+	cacheSizeBytes  int64
+	cacheSize       string
+	originURL       string
+	basePath        string
+	minPeers        int
+	peerScheme      string
+	peerCA          string
+	peerCert        string
+	peerKey         string
+	peerSecret      string
+	latencyBuckets  string
+	enablePprof     bool
+	warmConcurrency int
+	cacheTTL        time.Duration
+	contentType     string
+
+	peerMaxIdleConnsPerHost   int
+	peerDialTimeout           time.Duration
+	peerResponseHeaderTimeout time.Duration
+	staticPeers               string
+	serviceName               string
+	allowEmptySelfip          bool
+	compress                  bool
+	peerCompress              bool
+	compressMinBytes          int
+	peerUpdateDebounce        time.Duration
+	staleMaxAge               time.Duration
+	peerRemovalDelay          time.Duration
+	verifyPeers               bool
+	verifyPath                string
+	hashReplicas              int
+	hashFnName                string
+	dryRun                    bool
+	maxPeers                  int
+	fallbackToPods            bool
+	protoValues               bool
+	originMaxConcurrency      int
+	allNamespaces             bool
+	bootstrapPeers            string
+	peerReadyStabilization    time.Duration
+	cacheGzip                 bool
+	peerBreakerThreshold      int
+	peerBreakerBackoff        time.Duration
+	peerBreakerMaxBackoff     time.Duration
+	selectorFile              string
+	namespaceFile             string
+	reloadInterval            time.Duration
+	pollInterval              time.Duration
+	diskCacheDir              string
+	diskCacheTTL              time.Duration
+	diskCacheMaxSize          string
+	diskCacheCleanInterval    time.Duration
+	weightAnnotation          string
 )
 
 func init() {
@@ -39,65 +112,502 @@ func main() {
 	flag.StringVar(&namespace, "namespace", os.Getenv("POD_NAMESPACE"), "pod namespace")
 	flag.StringVar(&selector, "selector", os.Getenv("SELECTOR"), "selector")
 	flag.IntVar(&port, "port", 8080, "port")
+	flag.StringVar(&groupName, "group-name", envOr("GROUP_NAME", "testgroup"), "groupcache group name")
+	flag.Int64Var(&cacheSizeBytes, "cache-size-bytes", envInt64("CACHE_SIZE_BYTES", 3000000), "groupcache cache size in bytes")
+	flag.StringVar(&cacheSize, "cache-size", os.Getenv("CACHE_SIZE"), "groupcache cache size with unit, e.g. 512MiB or 3GB (overrides --cache-size-bytes)")
+	flag.StringVar(&originURL, "origin-url", os.Getenv("ORIGIN_URL"), "upstream origin to load values from (default: built-in demo getter)")
+	flag.StringVar(&basePath, "groupcache-base-path", envOr("GROUPCACHE_BASE_PATH", "/_groupcache/"), "HTTP path prefix for groupcache peer requests")
+	flag.IntVar(&minPeers, "min-peers", 0, "minimum number of peers required before reporting ready; peers are the ready endpoints of the service, so this pod only counts if the service sets publishNotReadyAddresses")
+	flag.StringVar(&peerScheme, "peer-scheme", envOr("PEER_SCHEME", "http"), "scheme used for peer communication (http or https)")
+	flag.StringVar(&peerCA, "peer-ca", os.Getenv("PEER_CA"), "CA bundle for verifying peers (https only)")
+	flag.StringVar(&peerCert, "peer-cert", os.Getenv("PEER_CERT"), "certificate presented to peers (https only)")
+	flag.StringVar(&peerKey, "peer-key", os.Getenv("PEER_KEY"), "private key for --peer-cert")
+	flag.StringVar(&peerSecret, "peer-secret", os.Getenv("PEER_SECRET"), "shared secret required on peer requests, /_stats/reset, /_warm, /_invalidate and DELETE /")
+	flag.StringVar(&latencyBuckets, "latency-buckets", os.Getenv("LATENCY_BUCKETS"), "comma separated load latency histogram buckets in seconds (default: prometheus default buckets)")
+	flag.BoolVar(&enablePprof, "enable-pprof", false, "serve pprof handlers under /debug/pprof/")
+	flag.IntVar(&warmConcurrency, "warm-concurrency", 10, "number of parallel loads or removes when warming or invalidating the cache via /_warm or /_invalidate")
+	flag.DurationVar(&cacheTTL, "cache-ttl", 0, "expiry of cached values unless the origin specifies one (0 means no expiry)")
+	flag.StringVar(&contentType, "content-type", os.Getenv("CONTENT_TYPE"), "content type of values served under /_raw/ (default: sniffed)")
+	flag.IntVar(&peerMaxIdleConnsPerHost, "peer-max-idle-conns-per-host", 0, "maximum idle keep-alive connections per peer (default 2)")
+	flag.DurationVar(&peerDialTimeout, "peer-dial-timeout", 0, "timeout for connecting to peers (default 30s)")
+	flag.DurationVar(&peerResponseHeaderTimeout, "peer-response-header-timeout", 0, "timeout for waiting on a peer's response headers (default no timeout)")
+	flag.DurationVar(&shutdownTimeout, "shutdown-timeout", 10*time.Second, "time to wait for in-flight requests on shutdown")
+	flag.StringVar(&staticPeers, "static-peers", os.Getenv("STATIC_PEERS"), "comma separated peer URLs to use instead of watching kubernetes, e.g. for local development")
+	flag.StringVar(&serviceName, "service-name", os.Getenv("SERVICE_NAME"), "watch the endpoints of this service instead of selecting them by --selector")
+	flag.BoolVar(&allowEmptySelfip, "allow-empty-selfip", false, "start without a known own ip, e.g. for single node testing")
+	flag.BoolVar(&compress, "compress", false, "gzip responses to clients that accept it")
+	flag.BoolVar(&peerCompress, "peer-compress", false, "gzip responses to peer requests")
+	flag.IntVar(&compressMinBytes, "compress-min-bytes", 1024, "minimum response size for --compress and --peer-compress")
+	flag.DurationVar(&peerUpdateDebounce, "peer-update-debounce", 0, "wait for k8s events to settle for this long before updating peers")
+	flag.DurationVar(&staleMaxAge, "stale-max-age", 0, "maximum time a value owned by another peer is served from the local hot cache without asking the owner again (0: until the value expires)")
+	flag.DurationVar(&peerRemovalDelay, "peer-removal-delay", 0, "keep removed peers for this long to let in-flight requests drain")
+	flag.DurationVar(&peerReadyStabilization, "peer-ready-stabilization", 0, "only add peers once they have been ready for this long")
+	flag.BoolVar(&verifyPeers, "verify-peers", false, "only use peers answering on --verify-path")
+	flag.StringVar(&verifyPath, "verify-path", envOr("VERIFY_PATH", "/health/live"), "path checked by --verify-peers")
+	flag.IntVar(&hashReplicas, "hash-replicas", 50, "virtual nodes per peer on the consistent hash ring; more even out the key distribution at the cost of memory and lookup time")
+	flag.StringVar(&hashFnName, "hash-fn", envOr("HASH_FN", "fnv1"), "consistent hash function (fnv1, fnv1a, crc32 or xxhash), must be the same on all peers")
+	flag.BoolVar(&dryRun, "dry-run", false, "only log the peers discovered in kubernetes, without using them")
+	flag.IntVar(&maxPeers, "max-peers", 0, "ignore peer lists with more peers than this (0 means unlimited)")
+	flag.BoolVar(&fallbackToPods, "fallback-to-pods", false, "watch pods if listing endpoints is forbidden")
+	flag.BoolVar(&protoValues, "proto-values", false, "store demo values as google.protobuf.StringValue messages, served under /_proto/")
+	flag.IntVar(&originMaxConcurrency, "origin-max-concurrency", 0, "maximum number of concurrent loads from the origin (0 means unlimited)")
+	flag.StringVar(&bootstrapPeers, "bootstrap-peers", os.Getenv("BOOTSTRAP_PEERS"), "comma separated peer URLs to use until the first sync with kubernetes completed")
+	flag.IntVar(&peerBreakerThreshold, "peer-breaker-threshold", 0, "stop asking a peer after this many consecutive failures and load locally instead (0 disables)")
+	flag.DurationVar(&peerBreakerBackoff, "peer-breaker-backoff", time.Second, "time before asking a failing peer again, doubled on every further failure")
+	flag.DurationVar(&peerBreakerMaxBackoff, "peer-breaker-max-backoff", time.Minute, "maximum time before asking a failing peer again")
+	flag.StringVar(&selectorFile, "selector-file", os.Getenv("SELECTOR_FILE"), "read the selector from this file and reload it on changes or SIGHUP (overrides --selector)")
+	flag.StringVar(&namespaceFile, "namespace-file", os.Getenv("NAMESPACE_FILE"), "read the namespace from this file and reload it on changes (overrides --namespace)")
+	flag.DurationVar(&reloadInterval, "reload-interval", 10*time.Second, "how often --selector-file and --namespace-file are checked for changes")
+	flag.DurationVar(&pollInterval, "poll-interval", 0, "list endpoints periodically instead of watching them, for clusters denying watch (0 watches)")
+	flag.StringVar(&diskCacheDir, "disk-cache-dir", os.Getenv("DISK_CACHE_DIR"), "keep loaded values in this directory and use them before asking the origin, e.g. after a restart")
+	flag.DurationVar(&diskCacheTTL, "disk-cache-ttl", 0, "maximum age of values in --disk-cache-dir (0: until the value expires)")
+	flag.StringVar(&diskCacheMaxSize, "disk-cache-max-size", os.Getenv("DISK_CACHE_MAX_SIZE"), "remove the oldest values from --disk-cache-dir above this size with unit, e.g. 10GiB (empty: no limit)")
+	flag.DurationVar(&diskCacheCleanInterval, "disk-cache-clean-interval", 10*time.Minute, "how often expired values are removed from --disk-cache-dir and --disk-cache-max-size is enforced")
+	flag.BoolVar(&cacheGzip, "cache-gzip", false, "store values gzip compressed in the cache, must be the same on all peers")
+	flag.StringVar(&weightAnnotation, "weight-annotation", os.Getenv("WEIGHT_ANNOTATION"), "pod annotation giving the peer a larger share of the keys, e.g. "+k8spool.DefaultWeightAnnotation+"; weights are read from the pods, so setting it switches from watching endpoints to watching endpoints and pods (auto)")
+	flag.BoolVar(&allNamespaces, "all-namespaces", false, "watch all namespaces if --namespace is empty instead of the pod's own namespace")
 	flag.Parse()
 
-	localpeer := fmt.Sprintf("http://%s:%d", selfip, port)
-	log.Printf("localpeer: %s", localpeer)
+	if selectorFile != "" {
+		sel, err := readConfigFile(selectorFile)
+		if err != nil {
+			log.Fatalf("Failed to read selector: %s", err)
+		}
+		selector = sel
+	}
+	if namespaceFile != "" {
+		ns, err := readConfigFile(namespaceFile)
+		if err != nil {
+			log.Fatalf("Failed to read namespace: %s", err)
+		}
+		namespace = ns
+	}
 
-	pool := groupcache.NewHTTPPoolOpts(localpeer, &groupcache.HTTPPoolOptions{})
+	if cacheSize != "" {
+		size, err := parseSize(cacheSize)
+		if err != nil {
+			log.Fatalf("Invalid cache size: %s", err)
+		}
+		cacheSizeBytes = size
+	}
+	if warmConcurrency < 1 {
+		log.Fatalf("Invalid warm concurrency %d: must be positive", warmConcurrency)
+	}
+	if hashReplicas < 1 {
+		log.Fatalf("Invalid hash replicas %d: must be positive", hashReplicas)
+	}
+	hashFn, err := parseHashFn(hashFnName)
+	if err != nil {
+		log.Fatalf("Invalid hash function: %s", err)
+	}
+	if !strings.HasPrefix(basePath, "/") || !strings.HasSuffix(basePath, "/") {
+		log.Fatalf("Invalid groupcache base path %q: must begin and end with /", basePath)
+	}
 
-	log.Printf("Starting k8s cache pool watcher with selector %s...", selector)
-	_, err := k8spool.New(k8spool.Config{
-		PeerScheme: "http",
-		PeerPort:   port,
-		Namespace:  namespace,
-		Selector:   selector,
-		OnUpdate: func(peers []string) {
-			log.Printf("update cache peers: %v", peers)
-			pool.Set(peers...)
-		},
-	})
+	var tlsConfig *tls.Config
+	switch peerScheme {
+	case "http":
+		if peerCA != "" || peerCert != "" || peerKey != "" {
+			log.Fatalf("--peer-ca, --peer-cert and --peer-key require --peer-scheme=https")
+		}
+	case "https":
+		if peerCert == "" || peerKey == "" {
+			log.Fatalf("--peer-scheme=https requires --peer-cert and --peer-key")
+		}
+		var err error
+		if tlsConfig, err = loadTLSConfig(peerCA, peerCert, peerKey); err != nil {
+			log.Fatalf("Failed to load peer TLS config: %s", err)
+		}
+	default:
+		log.Fatalf("Invalid peer scheme %q: must be http or https", peerScheme)
+	}
 
+	shutdownTracing, err := setupTracing(context.Background())
 	if err != nil {
-		log.Fatalf("Failed to start k8s peer watcher: %s", err)
+		log.Fatalf("Failed to set up tracing: %s", err)
 	}
 
-	group := groupcache.NewGroup("testgroup", 3000000, groupcache.GetterFunc(
+	if selfip == "" {
+		if ip, err := detectIP(); err != nil {
+			log.Printf("Failed to detect own ip: %s", err)
+		} else {
+			selfip = ip
+			log.Printf("Detected own ip %s", selfip)
+		}
+	}
+	if selfip == "" && !allowEmptySelfip {
+		log.Fatalf("Own ip unknown: set POD_IP or --selfip (or --allow-empty-selfip for single node testing)")
+	}
+
+	localpeer := k8spool.PeerURL(peerScheme, selfip, port, "")
+	log.Printf("localpeer: %s", localpeer)
+
+	buckets, err := parseBuckets(latencyBuckets)
+	if err != nil {
+		log.Fatalf("Invalid latency buckets: %s", err)
+	}
+	loadHistograms := metrics.NewLoadHistograms(groupName, buckets)
+
+	var peerTransport http.RoundTripper = newPeerTransport(tlsConfig, peerMaxIdleConnsPerHost, peerDialTimeout, peerResponseHeaderTimeout)
+	if peerSecret != "" {
+		peerTransport = &secretTransport{secret: peerSecret, next: peerTransport}
+	}
+	if staleMaxAge > 0 {
+		peerTransport = &staleBoundTransport{maxAge: staleMaxAge, next: peerTransport}
+	}
+	peerFailures := metrics.NewPeerFailuresCounter(groupName)
+	peerRejected := metrics.NewPeerRejectedCounter(groupName)
+	var breaker *breakerTransport
+	if peerBreakerThreshold > 0 {
+		breaker = &breakerTransport{
+			threshold:  peerBreakerThreshold,
+			backoff:    peerBreakerBackoff,
+			maxBackoff: peerBreakerMaxBackoff,
+			failures:   peerFailures,
+			rejected:   peerRejected,
+			next:       peerTransport,
+		}
+		peerTransport = breaker
+	}
+	peerTransport = loadHistograms.Transport(peerTransport)
+	loads := newLoadTimes(loadTimesEntries)
+	peerTransport = loads.Transport(peerTransport)
+	peerTransport = &traceTransport{next: peerTransport}
+
+	demoValue := func(ctx context.Context, id string) (string, time.Time, error) {
+		select {
+		case <-time.After(5 * time.Second):
+		case <-ctx.Done():
+			return "", time.Time{}, ctx.Err()
+		}
+		var expire time.Time
+		if cacheTTL > 0 {
+			expire = time.Now().Add(cacheTTL)
+		}
+		return fmt.Sprintf("Value %s calculated by %s", id, selfip), expire, nil
+	}
+	var origin groupcache.Getter = groupcache.GetterFunc(
 		func(ctx context.Context, id string, dest groupcache.Sink) error {
-			time.Sleep(5 * time.Second)
-			return dest.SetString(fmt.Sprintf("Value %s calculated by %s", id, selfip), time.Time{})
+			value, expire, err := demoValue(ctx, id)
+			if err != nil {
+				return err
+			}
+			return dest.SetString(value, expire)
 		},
-	))
+	)
+	if protoValues {
+		origin = getter.Proto(func(ctx context.Context, id string) (proto.Message, time.Time, error) {
+			value, expire, err := demoValue(ctx, id)
+			return wrapperspb.String(value), expire, err
+		})
+	}
+	if originURL != "" {
+		log.Printf("Loading values from origin %s", originURL)
+		httpGetter := getter.NewHTTP(originURL)
+		httpGetter.DefaultTTL = cacheTTL
+		origin = httpGetter
+	}
+	origin = outcomeGetter(origin, outcomeOrigin)
+	var limited *getter.Limited
+	if originMaxConcurrency > 0 {
+		limited = getter.NewLimited(origin, originMaxConcurrency)
+		origin = limited
+	}
+	var gzipped *getter.Gzipped
+	if cacheGzip {
+		gzipped = getter.NewGzipped(origin)
+		origin = gzipped
+	}
+	var disk *getter.Disk
+	if diskCacheDir != "" {
+		if disk, err = getter.NewDisk(origin, diskCacheDir, diskCacheTTL); err != nil {
+			log.Fatalf("Failed to set up disk cache: %s", err)
+		}
+		if diskCacheMaxSize != "" {
+			if disk.MaxBytes, err = parseSize(diskCacheMaxSize); err != nil {
+				log.Fatalf("Invalid disk cache max size: %s", err)
+			}
+		}
+		if diskCacheCleanInterval <= 0 {
+			log.Fatalf("Invalid disk cache clean interval %s: must be positive", diskCacheCleanInterval)
+		}
+		origin = outcomeGetter(disk, outcomeDisk)
+	}
+
+	mechanism := k8spool.WatchEndpoints
+	if weightAnnotation != "" {
+		log.Printf("Watching endpoints and pods to read the weight annotation %s", weightAnnotation)
+		mechanism = k8spool.WatchAuto
+	}
+	var static, bootstrap []string
+	if bootstrapPeers != "" {
+		bootstrap = strings.Split(bootstrapPeers, ",")
+	}
+	if staticPeers != "" {
+		mechanism = k8spool.WatchStatic
+		static = strings.Split(staticPeers, ",")
+	}
 
+	log.Printf("Starting k8s cache pool watcher with selector %s...", selector)
 	reg := prometheus.NewRegistry()
-	reg.Register(metrics.NewGroupCollector(group))
+	c, err := cache.New(context.Background(), cache.Options{
+		Self: localpeer,
+		Pool: groupcache.HTTPPoolOptions{
+			BasePath:  basePath,
+			Replicas:  hashReplicas,
+			HashFn:    hashFn,
+			Transport: func(context.Context) http.RoundTripper { return peerTransport },
+		},
+		Peers: k8spool.Config{
+			Mechanism:          mechanism,
+			StaticPeers:        static,
+			PeerScheme:         peerScheme,
+			PeerPort:           port,
+			Namespace:          namespace,
+			Selector:           selector,
+			ServiceName:        serviceName,
+			Debounce:           peerUpdateDebounce,
+			RemovalDelay:       peerRemovalDelay,
+			ReadyStabilization: peerReadyStabilization,
+			DryRun:             dryRun,
+			MaxPeers:           maxPeers,
+			FallbackToPods:     fallbackToPods,
+			PollInterval:       pollInterval,
+			AllNamespaces:      allNamespaces,
+			BootstrapPeers:     bootstrap,
+			WeightAnnotation:   weightAnnotation,
+			// Readiness depends on the peer list itself, so peers are verified
+			// via liveness by default to not wait on each other during startup.
+			VerifyPeers:  verifyPeers,
+			VerifyPath:   verifyPath,
+			VerifyClient: &http.Client{Transport: newPeerTransport(tlsConfig, 0, time.Second, 0), Timeout: time.Second},
+			OnUpdate: func(peers []string) {
+				log.Printf("update cache peers: %v", peers)
+				if breaker != nil {
+					breaker.prune(peers)
+				}
+			},
+		},
+		GroupName:  groupName,
+		CacheBytes: cacheSizeBytes,
+		Getter:     loadHistograms.Getter(getter.WithContext(loads.Getter(traceGetter(origin)))),
+		Registry:   reg,
+		Metrics: metrics.CollectorOptions{
+			Namespace: namespace,
+			Selector:  selector,
+			Pod:       os.Getenv("POD_NAME"),
+		},
+	})
+	if err != nil {
+		log.Fatalf("Failed to start cache: %s", err)
+	}
+	pool, peerWatcher, group := c.Pool, c.Peers, c.Group
+	log.Printf("Group %s: %d bytes shared by the main and the hot cache, the hot cache holding values loaded from peers is evicted first above 1/8 of the main cache", groupName, cacheSizeBytes)
+	groups := &groupRegistry{}
+	groups.Register(groupName, cacheSizeBytes)
+
+	reg.Register(metrics.NewBuildInfo(version, commit))
+	removes := metrics.NewRemovesCounter(groupName)
+	reg.Register(removes)
+	if limited != nil {
+		reg.Register(metrics.NewOriginInFlightGauge(groupName, limited.InFlight))
+	}
+	if gzipped != nil {
+		reg.Register(metrics.NewCompressionRatioGauge(groupName, gzipped.Ratio))
+	}
+	if disk != nil {
+		reg.Register(metrics.NewDiskHitsCounter(groupName, disk.Hits))
+		reg.Register(metrics.NewDiskMissesCounter(groupName, disk.Misses))
+	}
+	reg.Register(loadHistograms)
+	if peerBreakerThreshold > 0 {
+		reg.Register(peerFailures)
+		reg.Register(peerRejected)
+	}
+	reg.Register(collectors.NewGoCollector())
+	reg.Register(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
 
 	mux := http.NewServeMux()
-	mux.Handle("/_groupcache/", pool)
+	peerHandler := requirePeerAuth(peerSecret, peerCA != "", pool)
+	if peerCompress {
+		peerHandler = compressHandler(peerHandler, compressMinBytes)
+	}
+	mux.Handle(basePath, peerHandler)
 	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
-	mux.HandleFunc("/health/ready", func(rw http.ResponseWriter, _ *http.Request) {
+	if enablePprof {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+	mux.HandleFunc("/health/ready", k8spool.ReadinessHandler(peerWatcher, k8spool.ReadinessOptions{MinPeers: minPeers}))
+	mux.HandleFunc("/health/live", func(rw http.ResponseWriter, _ *http.Request) {
+		if !peerWatcher.Alive() {
+			http.Error(rw, "peer watcher stopped", http.StatusServiceUnavailable)
+			return
+		}
 		rw.Write([]byte("ok"))
 	})
-	mux.Handle("/", &server{group: group})
+	mux.HandleFunc("/_peers", func(rw http.ResponseWriter, _ *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(struct {
+			Self             string         `json:"self"`
+			Synced           bool           `json:"synced"`
+			Peers            []string       `json:"peers"`
+			LastSync         time.Time      `json:"last_sync"`
+			ResourceVersions []string       `json:"resource_versions"`
+			Weights          map[string]int `json:"weights"`
+		}{localpeer, peerWatcher.HasSynced(), peerWatcher.Peers(), peerWatcher.LastSync(), peerWatcher.LastResourceVersions(), peerWatcher.Weights()})
+	})
+	mux.HandleFunc("/_stats", statsHandler(group))
+	mux.Handle("/_stats/reset", requirePeerAuth(peerSecret, peerCA != "", statsResetHandler(group)))
+	mux.Handle("/_warm", requirePeerAuth(peerSecret, peerCA != "", warmHandler(group, warmConcurrency)))
+	mux.Handle("/_invalidate", requirePeerAuth(peerSecret, peerCA != "", invalidateHandler(group, warmConcurrency, removes)))
+	mux.Handle("/_groups", groups)
+	mux.HandleFunc(whichPeerPrefix, whichPeerHandler(c, localpeer))
+	var rawValues http.Handler = &rawHandler{group: group, contentType: contentType, gzipped: cacheGzip, loads: loads}
+	var values http.Handler = &server{group: group, removes: removes, gzipped: cacheGzip, loads: loads}
+	if compress {
+		rawValues = compressHandler(rawValues, compressMinBytes)
+		values = compressHandler(values, compressMinBytes)
+	}
+	mux.Handle(rawPrefix, rawValues)
+	if protoValues {
+		mux.Handle(protoPrefix, &protoHandler{group: group, newMessage: func() proto.Message { return &wrapperspb.StringValue{} }, gzipped: cacheGzip})
+	}
+	mux.Handle("/", requirePeerAuthFor(http.MethodDelete, peerSecret, peerCA != "", values))
 	server := http.Server{
-		Addr:    fmt.Sprintf(":%d", port),
-		Handler: mux,
+		Addr:      fmt.Sprintf(":%d", port),
+		Handler:   traceHandler(mux),
+		TLSConfig: tlsConfig,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if selectorFile != "" {
+		// SIGHUP re-reads the selector file without waiting for the next
+		// check. The --selector flag and $SELECTOR cannot change while
+		// running, so there is nothing to reload without a file.
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go watchConfigFile(ctx, selectorFile, selector, reloadInterval, hup, peerWatcher.SetSelector)
+	}
+	if namespaceFile != "" {
+		go watchConfigFile(ctx, namespaceFile, namespace, reloadInterval, nil, peerWatcher.SetNamespace)
+	}
+	if disk != nil {
+		go cleanDisk(ctx, disk, diskCacheCleanInterval)
+	}
+
+	go func() {
+		log.Println("Listening on ", server.Addr)
+		var err error
+		if tlsConfig != nil {
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Failed to listen: %s", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down server: %s", err)
 	}
+	if err := peerWatcher.CloseWithContext(shutdownCtx); err != nil {
+		log.Printf("Failed to stop peer watcher: %s", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Printf("Failed to flush traces: %s", err)
+	}
+}
 
-	log.Println("Listening on ", server.Addr)
-	server.ListenAndServe()
+func parseBuckets(s string) ([]float64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var buckets []float64
+	for _, b := range strings.Split(s, ",") {
+		f, err := strconv.ParseFloat(strings.TrimSpace(b), 64)
+		if err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, f)
+	}
+	return buckets, nil
+}
 
+// detectIP returns the first non-loopback unicast address of this host.
+func detectIP() (string, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return "", err
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || !ipNet.IP.IsGlobalUnicast() {
+			continue
+		}
+		return ipNet.IP.String(), nil
+	}
+	return "", fmt.Errorf("no non-loopback address found")
+}
+
+func envOr(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return def
+}
+
+func envInt64(key string, def int64) int64 {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		log.Fatalf("Invalid value for %s: %s", key, err)
+	}
+	return i
 }
 
 type server struct {
-	group *groupcache.Group
+	group   *groupcache.Group
+	removes prometheus.Counter
+	gzipped bool
+	loads   *loadTimes
 }
 
 func (s *server) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 
-	var result string
-	s.group.Get(req.Context(), strings.TrimPrefix(req.URL.Path, "/"), groupcache.StringSink(&result))
+	key := strings.TrimPrefix(req.URL.Path, "/")
+
+	if req.Method == http.MethodDelete {
+		if err := s.group.Remove(req.Context(), key); err != nil {
+			http.Error(rw, fmt.Sprintf("Failed to remove %s: %s", key, err), http.StatusInternalServerError)
+			return
+		}
+		s.removes.Inc()
+		rw.WriteHeader(http.StatusNoContent)
+		return
+	}
 
+	value, _ := getValue(req.Context(), s.group, key, s.gzipped)
+	result := value.String()
+
+	// The body names the serving peer, so peers only share a weak validator.
+	if notModified(rw, req, "W/"+etag(value), s.loads.lastModified(key)) {
+		rw.WriteHeader(http.StatusNotModified)
+		return
+	}
 	rw.Header().Add("Content-Type", "text/plain")
 	rw.WriteHeader(200)
 	rw.Write([]byte(fmt.Sprintf("Server: %s\nValue from cache: %s\n", selfip, result)))
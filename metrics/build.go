@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"runtime"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// NewBuildInfo returns the groupcache_build_info gauge. Empty version and
+// commit are filled in from the module and VCS information embedded by the
+// Go toolchain, if available.
+func NewBuildInfo(version, commit string) prometheus.Collector {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if version == "" {
+			version = info.Main.Version
+		}
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" && commit == "" {
+				commit = s.Value
+			}
+		}
+	}
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "groupcache_build_info",
+		Help: "Build information of the running binary (always 1)",
+		ConstLabels: prometheus.Labels{
+			"version":    version,
+			"commit":     commit,
+			"go_version": runtime.Version(),
+		},
+	})
+	gauge.Set(1)
+	return gauge
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// NewOriginInFlightGauge reports the number of loads currently running
+// against the origin as returned by inFlight.
+func NewOriginInFlightGauge(groupName string, inFlight func() int) prometheus.Collector {
+	return prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: fmt.Sprintf("groupcache_%s_origin_loads_in_flight", groupName),
+			Help: "Number of loads currently running against the origin",
+		},
+		func() float64 { return float64(inFlight()) },
+	)
+}
+
+// NewCompressionRatioGauge reports the compressed size of cached values
+// relative to their uncompressed size as returned by ratio.
+func NewCompressionRatioGauge(groupName string, ratio func() float64) prometheus.Collector {
+	return prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: fmt.Sprintf("groupcache_%s_compression_ratio", groupName),
+			Help: "Compressed size of the cached values relative to their uncompressed size",
+		},
+		ratio,
+	)
+}
+
+// NewPeerFailuresCounter counts failed requests to peers by peer URL.
+func NewPeerFailuresCounter(groupName string) *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: fmt.Sprintf("groupcache_%s_peer_request_failures_total", groupName),
+		Help: "Total number of requests to a peer that failed with a transport error or an unavailable status",
+	}, []string{"peer"})
+}
+
+// NewPeerRejectedCounter counts requests not sent to a peer because it failed
+// too often recently, by peer URL.
+func NewPeerRejectedCounter(groupName string) *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: fmt.Sprintf("groupcache_%s_peer_requests_rejected_total", groupName),
+		Help: "Total number of requests not sent to a peer because it failed too often recently",
+	}, []string{"peer"})
+}
+
+// NewDiskHitsCounter reports the number of loads served from the disk tier
+// as returned by hits.
+func NewDiskHitsCounter(groupName string, hits func() int64) prometheus.Collector {
+	return prometheus.NewCounterFunc(
+		prometheus.CounterOpts{
+			Name: fmt.Sprintf("groupcache_%s_disk_hits_total", groupName),
+			Help: "Total number of loads served from the disk tier",
+		},
+		func() float64 { return float64(hits()) },
+	)
+}
+
+// NewDiskMissesCounter reports the number of loads not found in the disk
+// tier as returned by misses.
+func NewDiskMissesCounter(groupName string, misses func() int64) prometheus.Collector {
+	return prometheus.NewCounterFunc(
+		prometheus.CounterOpts{
+			Name: fmt.Sprintf("groupcache_%s_disk_misses_total", groupName),
+			Help: "Total number of loads not found in the disk tier and loaded from the origin",
+		},
+		func() float64 { return float64(misses()) },
+	)
+}
@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/mailgun/groupcache/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// LoadHistograms records the duration of local and peer loads of a group.
+// Groupcache does not expose per request timings, so the getter and the peer
+// transport need to be wrapped using Getter and Transport.
+type LoadHistograms struct {
+	local prometheus.Histogram
+	peer  prometheus.Histogram
+}
+
+// NewLoadHistograms creates load duration histograms for the named group.
+// If buckets is nil prometheus.DefBuckets is used.
+func NewLoadHistograms(groupName string, buckets []float64) *LoadHistograms {
+	prefix := fmt.Sprintf("groupcache_%s_", groupName)
+	return &LoadHistograms{
+		local: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    prefix + "local_load_duration_seconds",
+			Help:    "Duration of loading values locally",
+			Buckets: buckets,
+		}),
+		peer: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    prefix + "peer_load_duration_seconds",
+			Help:    "Duration of loading values from peers",
+			Buckets: buckets,
+		}),
+	}
+}
+
+func (h *LoadHistograms) Describe(ch chan<- *prometheus.Desc) {
+	h.local.Describe(ch)
+	h.peer.Describe(ch)
+}
+
+func (h *LoadHistograms) Collect(ch chan<- prometheus.Metric) {
+	h.local.Collect(ch)
+	h.peer.Collect(ch)
+}
+
+// Getter wraps getter to observe the duration of local loads.
+func (h *LoadHistograms) Getter(getter groupcache.Getter) groupcache.Getter {
+	return InstrumentGetter(getter, h.local)
+}
+
+// InstrumentGetter wraps getter to observe the duration of each of its
+// invocations in seconds with obs, e.g. a histogram or summary.
+func InstrumentGetter(getter groupcache.Getter, obs prometheus.Observer) groupcache.Getter {
+	return groupcache.GetterFunc(func(ctx context.Context, key string, dest groupcache.Sink) error {
+		start := time.Now()
+		defer func() { obs.Observe(time.Since(start).Seconds()) }()
+		return getter.Get(ctx, key, dest)
+	})
+}
+
+// Transport wraps the peer transport to observe the duration of peer loads,
+// including reading the response body.
+func (h *LoadHistograms) Transport(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Method != http.MethodGet {
+			return next.RoundTrip(req)
+		}
+		start := time.Now()
+		resp, err := next.RoundTrip(req)
+		if err != nil {
+			h.peer.Observe(time.Since(start).Seconds())
+			return nil, err
+		}
+		resp.Body = &observingBody{ReadCloser: resp.Body, observe: func() {
+			h.peer.Observe(time.Since(start).Seconds())
+		}}
+		return resp, nil
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// observingBody calls observe once when the body is closed.
+type observingBody struct {
+	io.ReadCloser
+	observe func()
+}
+
+func (b *observingBody) Close() error {
+	if b.observe != nil {
+		b.observe()
+		b.observe = nil
+	}
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mailgun/groupcache/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestInstrumentGetter(t *testing.T) {
+	const sleep = 50 * time.Millisecond
+	hist := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_load_duration_seconds", Help: "test"})
+	getter := InstrumentGetter(groupcache.GetterFunc(func(ctx context.Context, key string, dest groupcache.Sink) error {
+		time.Sleep(sleep)
+		return dest.SetString(key, time.Time{})
+	}), hist)
+
+	for _, key := range []string{"a", "b"} {
+		var value string
+		if err := getter.Get(context.Background(), key, groupcache.StringSink(&value)); err != nil {
+			t.Fatal(err)
+		}
+		if value != key {
+			t.Errorf("got value %q, want %q", value, key)
+		}
+	}
+
+	if n := testutil.CollectAndCount(hist, "test_load_duration_seconds"); n != 1 {
+		t.Fatalf("got %d metrics, want 1", n)
+	}
+	var m dto.Metric
+	if err := hist.Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	if n := m.GetHistogram().GetSampleCount(); n != 2 {
+		t.Errorf("got %d samples, want 2", n)
+	}
+	min, max := 2*sleep.Seconds(), 2*(sleep+time.Second).Seconds()
+	if sum := m.GetHistogram().GetSampleSum(); sum < min || sum > max {
+		t.Errorf("got sum %fs, want between %fs and %fs", sum, min, max)
+	}
+}
@@ -7,12 +7,31 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-func NewGroupCollector(group *groupcache.Group) prometheus.Collector {
-	return &collector{group: group}
+// CollectorOptions configures identifying labels added to all metrics of a
+// collector. Empty values are omitted.
+type CollectorOptions struct {
+	Namespace string
+	Selector  string
+	Pod       string
+}
+
+func (o CollectorOptions) labels() prometheus.Labels {
+	labels := prometheus.Labels{}
+	for name, value := range map[string]string{"namespace": o.Namespace, "selector": o.Selector, "pod": o.Pod} {
+		if value != "" {
+			labels[name] = value
+		}
+	}
+	return labels
+}
+
+func NewGroupCollector(group *groupcache.Group, opts CollectorOptions) prometheus.Collector {
+	return &collector{group: group, labels: opts.labels()}
 }
 
 type collector struct {
-	group *groupcache.Group
+	group  *groupcache.Group
+	labels prometheus.Labels
 }
 
 func (c *collector) Describe(ch chan<- *prometheus.Desc) {
@@ -21,97 +40,151 @@ func (c *collector) Describe(ch chan<- *prometheus.Desc) {
 
 func (c *collector) Collect(ch chan<- prometheus.Metric) {
 	prefix := fmt.Sprintf("groupcache_%s_", c.group.Name())
+	labels := c.labels
 
 	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(prefix+"gets_total", "Total number of get requests, including from peers", nil, nil),
+		prometheus.NewDesc(prefix+"gets_total", "Total number of get requests, including from peers", nil, labels),
 		prometheus.CounterValue,
 		float64(c.group.Stats.Gets.Get()),
 	)
 
 	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(prefix+"cache_hits_total", "Total number of cache hits", nil, nil),
+		prometheus.NewDesc(prefix+"cache_hits_total", "Total number of cache hits", nil, labels),
 		prometheus.CounterValue,
 		float64(c.group.Stats.CacheHits.Get()),
 	)
 
+	// Despite its name GetFromPeersLatencyLower holds the slowest peer load
+	// seen so far; groupcache v2.4.1 has no upper bound counterpart. Use the
+	// peer_load_duration_seconds histogram for the distribution.
 	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(prefix+"peer_load_max_latency_seconds", "Longest time to load a value from peers", nil, nil),
+		prometheus.NewDesc(prefix+"peer_load_max_latency_seconds", "Longest time to load a value from peers", nil, labels),
 		prometheus.GaugeValue,
 		float64(c.group.Stats.GetFromPeersLatencyLower.Get())/1000,
 	)
 
 	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(prefix+"peer_loads_total", "Total number of remote load or remote cache hit (not an error)", nil, nil),
+		prometheus.NewDesc(prefix+"peer_loads_total", "Total number of remote load or remote cache hit (not an error)", nil, labels),
 		prometheus.CounterValue,
 		float64(c.group.Stats.PeerLoads.Get()),
 	)
 
 	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(prefix+"peer_errors_total", "Total number of errors loading from peers", nil, nil),
+		prometheus.NewDesc(prefix+"peer_errors_total", "Total number of errors loading from peers", nil, labels),
 		prometheus.CounterValue,
 		float64(c.group.Stats.PeerErrors.Get()),
 	)
 
 	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(prefix+"loads_total", "Total number of gets - cacheHits", nil, nil),
+		prometheus.NewDesc(prefix+"loads_total", "Total number of gets - cacheHits", nil, labels),
 		prometheus.CounterValue,
 		float64(c.group.Stats.Loads.Get()),
 	)
 	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(prefix+"loads_deduped_total", "Total number of whatever", nil, nil),
+		prometheus.NewDesc(prefix+"loads_deduped_total", "Total number of loads actually executed after concurrent loads of the same key were coalesced", nil, labels),
 		prometheus.CounterValue,
 		float64(c.group.Stats.LoadsDeduped.Get()),
 	)
 
 	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(prefix+"local_loads_total", "Total number of loading values locally", nil, nil),
+		prometheus.NewDesc(prefix+"cache_hit_ratio", "Ratio of cache hits to gets (0 without gets)", nil, labels),
+		prometheus.GaugeValue,
+		ratio(c.group.Stats.CacheHits.Get(), c.group.Stats.Gets.Get()),
+	)
+
+	ch <- prometheus.MustNewConstMetric(
+		prometheus.NewDesc(prefix+"peer_load_ratio", "Ratio of loads served by peers to all loads (0 without loads)", nil, labels),
+		prometheus.GaugeValue,
+		ratio(c.group.Stats.PeerLoads.Get(), c.group.Stats.Loads.Get()),
+	)
+
+	ch <- prometheus.MustNewConstMetric(
+		prometheus.NewDesc(prefix+"dedup_ratio", "Ratio of executed loads to all loads, lower values mean more loads were coalesced (0 without loads)", nil, labels),
+		prometheus.GaugeValue,
+		ratio(c.group.Stats.LoadsDeduped.Get(), c.group.Stats.Loads.Get()),
+	)
+
+	ch <- prometheus.MustNewConstMetric(
+		prometheus.NewDesc(prefix+"local_loads_total", "Total number of loading values locally", nil, labels),
 		prometheus.CounterValue,
 		float64(c.group.Stats.LocalLoads.Get()),
 	)
 
 	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(prefix+"local_load_errors_total", "Total number of errors loading values locally", nil, nil),
+		prometheus.NewDesc(prefix+"local_load_errors_total", "Total number of errors loading values locally", nil, labels),
 		prometheus.CounterValue,
 		float64(c.group.Stats.LocalLoadErrs.Get()),
 	)
 
 	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(prefix+"server_requests_total", "Total number of gets that came over the network from peers", nil, nil),
+		prometheus.NewDesc(prefix+"server_requests_total", "Total number of gets that came over the network from peers", nil, labels),
 		prometheus.CounterValue,
 		float64(c.group.Stats.LocalLoadErrs.Get()),
 	)
 
-	cacheStats(ch, prefix+"main_cache_", c.group.CacheStats(groupcache.MainCache))
-	cacheStats(ch, prefix+"hot_cache_", c.group.CacheStats(groupcache.HotCache))
+	mainCache := c.group.CacheStats(groupcache.MainCache)
+	hotCache := c.group.CacheStats(groupcache.HotCache)
+	cacheStats(ch, c.group.Name(), "main", labels, mainCache)
+	cacheStats(ch, c.group.Name(), "hot", labels, hotCache)
+
+	// groupcache v2.4.1 puts every value loaded from a peer into the hot
+	// cache and has no option to disable it. Once the group is full, hot
+	// entries are evicted first while they exceed 1/8 of the main cache.
+	// That pays off for read-mostly workloads with a few very popular keys,
+	// as it spares the owner's network. With many keys read about equally
+	// or frequently changing values it mostly duplicates the owners' entries
+	// and a high share means less room for the keys owned by this peer.
+	ch <- prometheus.MustNewConstMetric(
+		prometheus.NewDesc(prefix+"hot_cache_share", "Share of the hot cache in the bytes used by the group (0 if empty)", nil, labels),
+		prometheus.GaugeValue,
+		ratio(hotCache.Bytes, mainCache.Bytes+hotCache.Bytes),
+	)
+
+}
 
+// ratio returns n/d, or 0 if d is 0.
+func ratio(n, d int64) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
 }
 
-func cacheStats(ch chan<- prometheus.Metric, prefix string, stats groupcache.CacheStats) {
+// cacheStats emits the stats of one of the group's caches, labeled by group
+// name and cache type, so both caches share the same metric families.
+func cacheStats(ch chan<- prometheus.Metric, group, cache string, labels prometheus.Labels, stats groupcache.CacheStats) {
+	prefix := "groupcache_cache_"
+	variableLabels := []string{"group", "cache"}
 	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(prefix+"size_bytes", "Cache size", nil, nil),
+		prometheus.NewDesc(prefix+"size_bytes", "Cache size", variableLabels, labels),
 		prometheus.GaugeValue,
 		float64(stats.Bytes),
+		group, cache,
 	)
 	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(prefix+"items", "Number of items in the cache", nil, nil),
+		prometheus.NewDesc(prefix+"items", "Number of items in the cache", variableLabels, labels),
 		prometheus.GaugeValue,
 		float64(stats.Items),
+		group, cache,
 	)
 	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(prefix+"evictions_total", "Number of evictions", nil, nil),
+		prometheus.NewDesc(prefix+"evictions_total", "Number of evictions", variableLabels, labels),
 		prometheus.CounterValue,
 		float64(stats.Evictions),
+		group, cache,
 	)
 
 	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(prefix+"gets_total", "Number of gets", nil, nil),
+		prometheus.NewDesc(prefix+"gets_total", "Number of gets", variableLabels, labels),
 		prometheus.CounterValue,
 		float64(stats.Gets),
+		group, cache,
 	)
 
 	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(prefix+"hits_total", "Number of hits", nil, nil),
+		prometheus.NewDesc(prefix+"hits_total", "Number of hits", variableLabels, labels),
 		prometheus.CounterValue,
 		float64(stats.Hits),
+		group, cache,
 	)
 }
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"github.com/databus23/k8sgroupcache/k8spool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// NewPoolCollector exports the state of the k8s peer watcher. Before the
+// first sync it reports zero peers and a zero last update timestamp.
+func NewPoolCollector(pool *k8spool.K8sPool) prometheus.Collector {
+	return &poolCollector{pool: pool}
+}
+
+type poolCollector struct {
+	pool *k8spool.K8sPool
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(
+		prometheus.NewDesc("groupcache_k8spool_peers", "Number of peers in the current peer list", nil, nil),
+		prometheus.GaugeValue,
+		float64(len(c.pool.Peers())),
+	)
+	var synced float64
+	if c.pool.HasSynced() {
+		synced = 1
+	}
+	ch <- prometheus.MustNewConstMetric(
+		prometheus.NewDesc("groupcache_k8spool_synced", "Whether the k8s peer watcher has completed its initial sync (0 or 1)", nil, nil),
+		prometheus.GaugeValue,
+		synced,
+	)
+	var lastUpdate float64
+	if t := c.pool.LastUpdate(); !t.IsZero() {
+		lastUpdate = float64(t.UnixNano()) / 1e9
+	}
+	ch <- prometheus.MustNewConstMetric(
+		prometheus.NewDesc("groupcache_k8spool_last_update_timestamp_seconds", "Unix timestamp of the last peer list update", nil, nil),
+		prometheus.GaugeValue,
+		lastUpdate,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		prometheus.NewDesc("groupcache_k8spool_updates_total", "Total number of peer list updates", nil, nil),
+		prometheus.CounterValue,
+		float64(c.pool.UpdateCount()),
+	)
+	var lastSync float64
+	if t := c.pool.LastSync(); !t.IsZero() {
+		lastSync = float64(t.UnixNano()) / 1e9
+	}
+	ch <- prometheus.MustNewConstMetric(
+		prometheus.NewDesc("groupcache_k8spool_last_sync_timestamp_seconds", "Unix timestamp of the last peer recomputation from the informer stores", nil, nil),
+		prometheus.GaugeValue,
+		lastSync,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		prometheus.NewDesc("groupcache_k8spool_watch_errors_total", "Total number of failed watches", nil, nil),
+		prometheus.CounterValue,
+		float64(c.pool.WatchErrors()),
+	)
+	eventErrors := prometheus.NewDesc("groupcache_k8spool_event_errors_total", "Total number of informer events that failed to be processed", []string{"reason"}, nil)
+	for reason, n := range c.pool.EventErrors() {
+		ch <- prometheus.MustNewConstMetric(eventErrors, prometheus.CounterValue, float64(n), reason)
+	}
+}
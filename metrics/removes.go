@@ -0,0 +1,16 @@
+package metrics
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// NewRemovesCounter counts keys removed from the cache via the HTTP API, as
+// groupcache itself does not keep track of removes.
+func NewRemovesCounter(groupName string) prometheus.Counter {
+	return prometheus.NewCounter(prometheus.CounterOpts{
+		Name: fmt.Sprintf("groupcache_%s_removes_total", groupName),
+		Help: "Total number of keys removed from the cache",
+	})
+}
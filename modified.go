@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mailgun/groupcache/v2"
+	"github.com/mailgun/groupcache/v2/lru"
+)
+
+// loadTimes remembers when this peer last loaded the value of a key, either
+// from the origin or from the key's owner, to serve it with a Last-Modified
+// header. Values keep their load time while they are cached, so the time is
+// only lost if the key is evicted from the bounded list, it is then taken
+// from the next request.
+type loadTimes struct {
+	mu    sync.Mutex
+	times *lru.Cache
+}
+
+// loadTimesEntries bounds the keys whose load time is kept, at about 100
+// bytes each.
+const loadTimesEntries = 100000
+
+func newLoadTimes(maxEntries int) *loadTimes {
+	return &loadTimes{times: lru.New(maxEntries)}
+}
+
+func (l *loadTimes) loaded(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.times.Add(key, time.Now().UTC().Truncate(time.Second), time.Time{})
+}
+
+// lastModified returns the load time of the value of key.
+func (l *loadTimes) lastModified(key string) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if t, ok := l.times.Get(key); ok {
+		return t.(time.Time)
+	}
+	t := time.Now().UTC().Truncate(time.Second)
+	l.times.Add(key, t, time.Time{})
+	return t
+}
+
+// Getter records loads from the origin.
+func (l *loadTimes) Getter(getter groupcache.Getter) groupcache.Getter {
+	return groupcache.GetterFunc(func(ctx context.Context, key string, dest groupcache.Sink) error {
+		err := getter.Get(ctx, key, dest)
+		if err == nil {
+			l.loaded(key)
+		}
+		return err
+	})
+}
+
+// Transport records loads from peers. groupcache requests keys as the last
+// query escaped path segment.
+func (l *loadTimes) Transport(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := next.RoundTrip(req)
+		if err == nil && req.Method == http.MethodGet && resp.StatusCode == http.StatusOK {
+			if key, err := url.QueryUnescape(path.Base(req.URL.EscapedPath())); err == nil {
+				l.loaded(key)
+			}
+		}
+		return resp, err
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// notModified sets the ETag and Last-Modified headers and reports whether
+// the request's preconditions show the client has this representation
+// already. If-None-Match takes precedence over If-Modified-Since.
+func notModified(rw http.ResponseWriter, req *http.Request, tag string, modified time.Time) bool {
+	rw.Header().Set("ETag", tag)
+	rw.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	if header := req.Header.Get("If-None-Match"); header != "" {
+		return etagMatches(header, strings.TrimPrefix(tag, "W/"))
+	}
+	since, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	return err == nil && !modified.After(since)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mailgun/groupcache/v2"
+)
+
+func TestConditionalRaw(t *testing.T) {
+	loads := newLoadTimes(10)
+	group := groupcache.NewGroup("conditional-test", 1<<20, loads.Getter(groupcache.GetterFunc(func(ctx context.Context, key string, dest groupcache.Sink) error {
+		return dest.SetString("value of "+key, time.Time{})
+	})))
+	handler := compressHandler(&rawHandler{group: group, contentType: "text/plain", loads: loads}, 0)
+
+	get := func(header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", rawPrefix+"key", nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := get(nil)
+	if first.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200", first.Code)
+	}
+	tag, modified := first.Header().Get("ETag"), first.Header().Get("Last-Modified")
+	if tag == "" || modified == "" {
+		t.Fatalf("got ETag %q and Last-Modified %q, want both", tag, modified)
+	}
+	since, err := http.ParseTime(modified)
+	if err != nil {
+		t.Fatalf("invalid Last-Modified %q: %s", modified, err)
+	}
+
+	gzipped := get(http.Header{"Accept-Encoding": {"gzip"}})
+	if gzipped.Header().Get("Content-Encoding") != "gzip" || gzipped.Header().Get("Last-Modified") != modified {
+		t.Errorf("got encoding %q and Last-Modified %q for gzip, want gzip and %q", gzipped.Header().Get("Content-Encoding"), gzipped.Header().Get("Last-Modified"), modified)
+	}
+
+	tests := []struct {
+		name   string
+		header http.Header
+		status int
+	}{
+		{"matching ETag", http.Header{"If-None-Match": {tag}}, http.StatusNotModified},
+		{"other ETag", http.Header{"If-None-Match": {`"other"`}}, http.StatusOK},
+		{"ETag takes precedence", http.Header{"If-None-Match": {`"other"`}, "If-Modified-Since": {modified}}, http.StatusOK},
+		{"not modified since", http.Header{"If-Modified-Since": {modified}}, http.StatusNotModified},
+		{"modified since", http.Header{"If-Modified-Since": {since.Add(-time.Second).Format(http.TimeFormat)}}, http.StatusOK},
+		{"not modified gzip", http.Header{"If-None-Match": {tag}, "Accept-Encoding": {"gzip"}}, http.StatusNotModified},
+	}
+	for _, tt := range tests {
+		rec := get(tt.header)
+		if rec.Code != tt.status {
+			t.Errorf("%s: got status %d, want %d", tt.name, rec.Code, tt.status)
+		}
+		if rec.Code != http.StatusNotModified {
+			continue
+		}
+		if rec.Body.Len() != 0 || rec.Header().Get("Content-Encoding") != "" {
+			t.Errorf("%s: got %d body bytes with encoding %q, want an empty body", tt.name, rec.Body.Len(), rec.Header().Get("Content-Encoding"))
+		}
+		if rec.Header().Get("Last-Modified") != modified {
+			t.Errorf("%s: got Last-Modified %q, want %q", tt.name, rec.Header().Get("Last-Modified"), modified)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/mailgun/groupcache/v2"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const protoPrefix = "/_proto/"
+
+// protoHandler serves values of a group holding protobuf messages of the
+// type returned by newMessage. The binary message is sent unless the client
+// accepts JSON only.
+type protoHandler struct {
+	group      *groupcache.Group
+	newMessage func() proto.Message
+	gzipped    bool
+}
+
+func (h *protoHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	key := strings.TrimPrefix(req.URL.Path, protoPrefix)
+
+	value, err := getValue(req.Context(), h.group, key, h.gzipped)
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("Failed to get %s: %s", key, err), http.StatusInternalServerError)
+		return
+	}
+	if !strings.Contains(req.Header.Get("Accept"), "application/json") {
+		rw.Header().Set("Content-Type", "application/x-protobuf")
+		value.WriteTo(rw)
+		return
+	}
+
+	msg := h.newMessage()
+	if err := proto.Unmarshal(value.ByteSlice(), msg); err != nil {
+		http.Error(rw, fmt.Sprintf("Failed to decode %s: %s", key, err), http.StatusInternalServerError)
+		return
+	}
+	body, err := protojson.Marshal(proto.MessageV2(msg))
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("Failed to encode %s: %s", key, err), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(body)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mailgun/groupcache/v2"
+)
+
+const rawPrefix = "/_raw/"
+
+// rawHandler serves cached values as raw bytes, without converting them to
+// strings. If contentType is empty it is sniffed from the value. Gzipped
+// values are sent as is to clients accepting gzip.
+type rawHandler struct {
+	group       *groupcache.Group
+	contentType string
+	gzipped     bool
+	loads       *loadTimes
+}
+
+func (h *rawHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	key := strings.TrimPrefix(req.URL.Path, rawPrefix)
+
+	if h.gzipped && acceptsGzip(req) {
+		var value groupcache.ByteView
+		if err := tracedGet(req.Context(), h.group, key, groupcache.ByteViewSink(&value)); err != nil {
+			http.Error(rw, fmt.Sprintf("Failed to get %s: %s", key, err), http.StatusInternalServerError)
+			return
+		}
+		contentType := h.contentType
+		if contentType == "" {
+			zr, err := gzip.NewReader(value.Reader())
+			if err != nil {
+				http.Error(rw, fmt.Sprintf("Failed to decompress %s: %s", key, err), http.StatusInternalServerError)
+				return
+			}
+			head := make([]byte, 512)
+			n, _ := io.ReadFull(zr, head)
+			contentType = http.DetectContentType(head[:n])
+		}
+		rw.Header().Set("Content-Encoding", "gzip")
+		rw.Header().Add("Vary", "Accept-Encoding")
+		h.write(rw, req, key, value, contentType, true)
+		return
+	}
+
+	value, err := getValue(req.Context(), h.group, key, h.gzipped)
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("Failed to get %s: %s", key, err), http.StatusInternalServerError)
+		return
+	}
+
+	contentType := h.contentType
+	if contentType == "" {
+		n := value.Len()
+		if n > 512 {
+			n = 512
+		}
+		contentType = http.DetectContentType(value.Slice(0, n).ByteSlice())
+	}
+	h.write(rw, req, key, value, contentType, false)
+}
+
+// write sends value with its load time and a validator derived from it,
+// which is weak if value is not the identity representation.
+func (h *rawHandler) write(rw http.ResponseWriter, req *http.Request, key string, value groupcache.ByteView, contentType string, weak bool) {
+	tag := etag(value)
+	if weak {
+		tag = "W/" + tag
+	}
+	if notModified(rw, req, tag, h.loads.lastModified(key)) {
+		rw.WriteHeader(http.StatusNotModified)
+		return
+	}
+	rw.Header().Set("Content-Type", contentType)
+	rw.Header().Set("Content-Length", strconv.Itoa(value.Len()))
+	value.WriteTo(rw)
+}
+
+// etag derives a strong ETag from the value only, so all peers return the
+// same ETag for the same value.
+func etag(value groupcache.ByteView) string {
+	h := sha256.New()
+	value.WriteTo(h)
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// etagMatches reports whether the If-None-Match header matches tag, using
+// the weak comparison required for If-None-Match.
+func etagMatches(header, tag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == "*" || t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// readConfigFile returns the trimmed content of a file, e.g. a key of a
+// mounted ConfigMap.
+func readConfigFile(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// watchConfigFile calls apply with the content of path whenever it differs
+// from current, checking every interval and on every value received from
+// reload until ctx is done. Kubernetes updates ConfigMap volumes by swapping a
+// symlink, so the file is re-read instead of watched for events. If apply
+// fails the change is retried on the next check.
+func watchConfigFile(ctx context.Context, path, current string, interval time.Duration, reload <-chan os.Signal, apply func(string) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-reload:
+		}
+		value, err := readConfigFile(path)
+		if err != nil {
+			log.Printf("Failed to read %s: %s", path, err)
+			continue
+		}
+		if value == current {
+			continue
+		}
+		log.Printf("Applying %q from %s", value, path)
+		if err := apply(value); err != nil {
+			log.Printf("Failed to apply %s, keeping %q: %s", path, current, err)
+			continue
+		}
+		current = value
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchConfigFileReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "selector")
+	if err := os.WriteFile(path, []byte("app=new\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reload := make(chan os.Signal, 1)
+	applied := make(chan string, 1)
+	go watchConfigFile(ctx, path, "app=old", time.Hour, reload, func(value string) error {
+		applied <- value
+		return nil
+	})
+
+	reload <- os.Interrupt
+	select {
+	case value := <-applied:
+		if value != "app=new" {
+			t.Errorf("got %q applied, want %q", value, "app=new")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("file not re-read on reload")
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/databus23/k8sgroupcache/cache"
+)
+
+const whichPeerPrefix = "/_whichpeer/"
+
+// whichPeerHandler reports the owner of the key following whichPeerPrefix,
+// see cache.Cache.WhichPeer.
+func whichPeerHandler(c *cache.Cache, self string) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		key := strings.TrimPrefix(req.URL.Path, whichPeerPrefix)
+		owner := c.WhichPeer(key)
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(struct {
+			Key   string `json:"key"`
+			Owner string `json:"owner"`
+			Self  bool   `json:"self"`
+		}{key, owner, owner == self})
+	}
+}
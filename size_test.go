@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "3000000", want: 3000000},
+		{in: "0", want: 0},
+		{in: "100b", want: 100},
+		{in: "1KB", want: 1000},
+		{in: "3GB", want: 3e9},
+		{in: "2tb", want: 2e12},
+		{in: "1KiB", want: 1024},
+		{in: "512MiB", want: 512 << 20},
+		{in: "1gib", want: 1 << 30},
+		{in: "1TiB", want: 1 << 40},
+		{in: "1.5KiB", want: 1536},
+		{in: "  64MiB\t", want: 64 << 20},
+		{in: "64 MiB", want: 64 << 20},
+		{in: "8388607TiB", want: 8388607 << 40},
+		{in: "8388608TiB", wantErr: true},
+		{in: "1e30", wantErr: true},
+		{in: "", wantErr: true},
+		{in: "MiB", wantErr: true},
+		{in: "abc", wantErr: true},
+		{in: "-1MiB", wantErr: true},
+		{in: "1.2.3MB", wantErr: true},
+		{in: "10PB", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseSize(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseSize(%q) = %d, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseSize(%q) failed: %s", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseSize(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
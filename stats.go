@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mailgun/groupcache/v2"
+)
+
+func statsHandler(group *groupcache.Group) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s := &group.Stats
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(map[string]interface{}{
+			"group": group.Name(),
+			"stats": map[string]int64{
+				"gets":                         s.Gets.Get(),
+				"cache_hits":                   s.CacheHits.Get(),
+				"get_from_peers_latency_lower": s.GetFromPeersLatencyLower.Get(),
+				"peer_loads":                   s.PeerLoads.Get(),
+				"peer_errors":                  s.PeerErrors.Get(),
+				"loads":                        s.Loads.Get(),
+				"loads_deduped":                s.LoadsDeduped.Get(),
+				"local_loads":                  s.LocalLoads.Get(),
+				"local_load_errors":            s.LocalLoadErrs.Get(),
+				"server_requests":              s.ServerRequests.Get(),
+			},
+			"main_cache": group.CacheStats(groupcache.MainCache),
+			"hot_cache":  group.CacheStats(groupcache.HotCache),
+		})
+	}
+}
+
+// statsResetHandler zeroes the group's Stats counters. The Prometheus
+// counters exported from them are reset as well, which scrapers handle like a
+// restart. The per cache statistics are kept by groupcache internally and can
+// not be reset without recreating the group, so they are left untouched. It is
+// served with the same authentication as peer requests.
+func statsResetHandler(group *groupcache.Group) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s := &group.Stats
+		for _, stat := range []*groupcache.AtomicInt{
+			&s.Gets, &s.CacheHits, &s.GetFromPeersLatencyLower, &s.PeerLoads, &s.PeerErrors,
+			&s.Loads, &s.LoadsDeduped, &s.LocalLoads, &s.LocalLoadErrs, &s.ServerRequests,
+		} {
+			stat.Store(0)
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}
+}
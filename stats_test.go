@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mailgun/groupcache/v2"
+)
+
+func TestStatsResetRequiresSecret(t *testing.T) {
+	group := groupcache.NewGroup("stats-reset-test", 1<<20, groupcache.GetterFunc(func(ctx context.Context, key string, dest groupcache.Sink) error {
+		return dest.SetString(key, time.Time{})
+	}))
+	var value string
+	if err := group.Get(context.Background(), "key", groupcache.StringSink(&value)); err != nil {
+		t.Fatal(err)
+	}
+	handler := requirePeerAuth("secret", false, statsResetHandler(group))
+
+	tests := []struct {
+		method string
+		secret string
+		status int
+		gets   int64
+	}{
+		{method: "POST", status: http.StatusUnauthorized, gets: 1},
+		{method: "POST", secret: "wrong", status: http.StatusUnauthorized, gets: 1},
+		{method: "GET", secret: "secret", status: http.StatusMethodNotAllowed, gets: 1},
+		{method: "POST", secret: "secret", status: http.StatusNoContent, gets: 0},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/_stats/reset", nil)
+		if tt.secret != "" {
+			req.Header.Set(secretHeader, tt.secret)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s with secret %q: got status %d, want %d", tt.method, tt.secret, rec.Code, tt.status)
+		}
+		if gets := group.Stats.Gets.Get(); gets != tt.gets {
+			t.Errorf("%s with secret %q: got %d gets, want %d", tt.method, tt.secret, gets, tt.gets)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sync/atomic"
+
+	"github.com/mailgun/groupcache/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/databus23/k8sgroupcache")
+
+// setupTracing exports spans via OTLP/HTTP if OTEL_EXPORTER_OTLP_ENDPOINT or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is set, configured by the standard
+// OTEL_* variables. Otherwise the global no-op tracer is kept. The returned
+// function flushes pending spans.
+func setupTracing(ctx context.Context) (func(context.Context) error, error) {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// traceHandler starts a server span for every request, continuing the trace
+// of peers and clients propagating one. Spans are named after the route mux
+// matches, paths contain keys and are only recorded as an attribute.
+func traceHandler(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, route := mux.Handler(req)
+		ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+		ctx, span := tracer.Start(ctx, req.Method+" "+route, trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.method", req.Method),
+				attribute.String("http.route", route),
+				attribute.String("http.target", req.URL.Path),
+			))
+		defer span.End()
+		rec := &statusRecorder{ResponseWriter: rw, status: http.StatusOK}
+		mux.ServeHTTP(rec, req.WithContext(ctx))
+		span.SetAttributes(attribute.Int("http.status_code", rec.status))
+		if rec.status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Cache outcomes of a traced get, recorded by the peer transport and the
+// getters wrapped by outcomeGetter in the context of the get.
+const (
+	outcomeCache int32 = iota
+	outcomePeer
+	outcomeDisk
+	outcomeOrigin
+)
+
+var outcomeNames = map[int32]string{outcomeCache: "cache", outcomePeer: "peer", outcomeDisk: "disk", outcomeOrigin: "origin"}
+
+type outcomeKey struct{}
+
+func setOutcome(ctx context.Context, outcome int32) {
+	if o, ok := ctx.Value(outcomeKey{}).(*int32); ok {
+		atomic.StoreInt32(o, outcome)
+	}
+}
+
+// tracedGet gets key from group in a span recording the key and whether the
+// value came from the local cache, a peer, the disk cache or the origin.
+// groupcache dedups concurrent loads, so only the get starting a load sees it.
+func tracedGet(ctx context.Context, group *groupcache.Group, key string, dest groupcache.Sink) error {
+	outcome := outcomeCache
+	ctx = context.WithValue(ctx, outcomeKey{}, &outcome)
+	ctx, span := tracer.Start(ctx, "groupcache.Get", trace.WithAttributes(
+		attribute.String("groupcache.group", group.Name()),
+		attribute.String("groupcache.key", key),
+	))
+	defer span.End()
+	err := group.Get(ctx, key, dest)
+	span.SetAttributes(attribute.String("groupcache.outcome", outcomeNames[atomic.LoadInt32(&outcome)]))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// traceTransport wraps peer requests in a client span and propagates the
+// trace to the peer.
+type traceTransport struct {
+	next http.RoundTripper
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	setOutcome(req.Context(), outcomePeer)
+	ctx, span := tracer.Start(req.Context(), "groupcache.peer "+req.Method, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("peer", req.URL.Scheme+"://"+req.URL.Host)))
+	defer span.End()
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return resp, err
+	}
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+	return resp, nil
+}
+
+// outcomeGetter records outcome before calling getter. Getters called later
+// in the same get overwrite it, so wrapping both the disk cache and the origin
+// behind it records which of them served the value.
+func outcomeGetter(getter groupcache.Getter, outcome int32) groupcache.Getter {
+	return groupcache.GetterFunc(func(ctx context.Context, key string, dest groupcache.Sink) error {
+		setOutcome(ctx, outcome)
+		return getter.Get(ctx, key, dest)
+	})
+}
+
+// traceGetter wraps local loads in a span, which covers the disk cache if
+// one is configured and the origin.
+func traceGetter(getter groupcache.Getter) groupcache.Getter {
+	return groupcache.GetterFunc(func(ctx context.Context, key string, dest groupcache.Sink) error {
+		ctx, span := tracer.Start(ctx, "groupcache.load", trace.WithAttributes(attribute.String("groupcache.key", key)))
+		defer span.End()
+		err := getter.Get(ctx, key, dest)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return err
+	})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/databus23/k8sgroupcache/getter"
+	"github.com/mailgun/groupcache/v2"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestOutcomeGetter(t *testing.T) {
+	var loads int32
+	origin := outcomeGetter(groupcache.GetterFunc(func(ctx context.Context, key string, dest groupcache.Sink) error {
+		atomic.AddInt32(&loads, 1)
+		return dest.SetString("value of "+key, time.Time{})
+	}), outcomeOrigin)
+	disk, err := getter.NewDisk(origin, t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := traceGetter(outcomeGetter(disk, outcomeDisk))
+
+	for _, want := range []int32{outcomeOrigin, outcomeDisk} {
+		outcome := outcomeCache
+		ctx := context.WithValue(context.Background(), outcomeKey{}, &outcome)
+		var value string
+		if err := g.Get(ctx, "key", groupcache.StringSink(&value)); err != nil {
+			t.Fatal(err)
+		}
+		if value != "value of key" {
+			t.Errorf("got value %q, want %q", value, "value of key")
+		}
+		if outcome != want {
+			t.Errorf("got outcome %s, want %s", outcomeNames[outcome], outcomeNames[want])
+		}
+	}
+	if loads != 1 {
+		t.Errorf("got %d origin loads, want 1", loads)
+	}
+}
+
+func TestTraceHandlerRoute(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	mux := http.NewServeMux()
+	mux.HandleFunc(rawPrefix, func(http.ResponseWriter, *http.Request) {})
+	mux.HandleFunc("/", func(http.ResponseWriter, *http.Request) {})
+	handler := traceHandler(mux)
+
+	tests := []struct {
+		method, path, want string
+	}{
+		{"GET", rawPrefix + "secret-key", "GET " + rawPrefix},
+		{"DELETE", "/secret-key", "DELETE /"},
+	}
+	for _, tt := range tests {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+		spans := recorder.Ended()
+		if got := spans[len(spans)-1].Name(); got != tt.want {
+			t.Errorf("%s %s: got span %q, want %q", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
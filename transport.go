@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/mailgun/groupcache/v2/groupcachepb"
+)
+
+// loadTLSConfig builds the TLS configuration used for peer communication.
+// The CA bundle is used both to verify peer servers and client certificates.
+func loadTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	conf := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		conf.RootCAs = pool
+		conf.ClientCAs = pool
+		conf.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load certificate: %w", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
+
+// requireClientCert rejects requests that did not present a client
+// certificate signed by the configured CA.
+func requireClientCert(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 {
+			http.Error(rw, "client certificate required", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(rw, req)
+	})
+}
+
+// newPeerTransport returns the base transport for peer requests. Unset
+// options keep the defaults of http.DefaultTransport.
+func newPeerTransport(tlsConfig *tls.Config, maxIdleConnsPerHost int, dialTimeout, responseHeaderTimeout time.Duration) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	if maxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	}
+	if dialTimeout > 0 {
+		transport.DialContext = (&net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	}
+	transport.ResponseHeaderTimeout = responseHeaderTimeout
+	return transport
+}
+
+const secretHeader = "X-Groupcache-Secret"
+
+// secretTransport attaches the shared peer secret to outgoing requests.
+type secretTransport struct {
+	secret string
+	next   http.RoundTripper
+}
+
+func (t *secretTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set(secretHeader, t.secret)
+	return t.next.RoundTrip(req)
+}
+
+// requireSecret rejects requests that do not carry the shared peer secret.
+func requireSecret(secret string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(req.Header.Get(secretHeader)), []byte(secret)) != 1 {
+			http.Error(rw, "invalid peer secret", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(rw, req)
+	})
+}
+
+// requirePeerAuth applies the checks required on peer requests to next: a
+// client certificate if clientCert is set and the shared secret if secret is
+// not empty.
+func requirePeerAuth(secret string, clientCert bool, next http.Handler) http.Handler {
+	if clientCert {
+		next = requireClientCert(next)
+	}
+	if secret != "" {
+		next = requireSecret(secret, next)
+	}
+	return next
+}
+
+// requirePeerAuthFor applies requirePeerAuth to requests with method only,
+// leaving the other requests to next unchecked.
+func requirePeerAuthFor(method, secret string, clientCert bool, next http.Handler) http.Handler {
+	authed := requirePeerAuth(secret, clientCert, next)
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method == method {
+			authed.ServeHTTP(rw, req)
+			return
+		}
+		next.ServeHTTP(rw, req)
+	})
+}
+
+// staleBoundTransport caps the expiry of values fetched from peers to maxAge.
+//
+// groupcache stores every value fetched from its owner in the local hot cache
+// and serves later gets for the key from there without asking the owner
+// again. Such copies are not invalidated when the owner's value changes or is
+// removed, they live until they expire or are evicted, so reads of hot keys
+// may be stale. Bounding the expiry of fetched values bounds that staleness;
+// the owner's own copy keeps the expiry set by the getter.
+type staleBoundTransport struct {
+	maxAge time.Duration
+	next   http.RoundTripper
+}
+
+func (t *staleBoundTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || req.Method != http.MethodGet || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var out pb.GetResponse
+	if err := proto.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("Failed to decode peer response: %w", err)
+	}
+	maxExpire := time.Now().Add(t.maxAge).UnixNano()
+	if out.GetExpire() == 0 || out.GetExpire() > maxExpire {
+		out.Expire = &maxExpire
+		if body, err = proto.Marshal(&out); err != nil {
+			return nil, err
+		}
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Del("Content-Length")
+	return resp, nil
+}
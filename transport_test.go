@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequirePeerAuthFor(t *testing.T) {
+	handler := requirePeerAuthFor(http.MethodDelete, "secret", false, http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		method string
+		secret string
+		status int
+	}{
+		{method: "GET", status: http.StatusNoContent},
+		{method: "DELETE", status: http.StatusUnauthorized},
+		{method: "DELETE", secret: "wrong", status: http.StatusUnauthorized},
+		{method: "DELETE", secret: "secret", status: http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/key", nil)
+		if tt.secret != "" {
+			req.Header.Set(secretHeader, tt.secret)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s with secret %q: got status %d, want %d", tt.method, tt.secret, rec.Code, tt.status)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/mailgun/groupcache/v2"
+)
+
+type warmResult struct {
+	Succeeded int               `json:"succeeded"`
+	Failed    map[string]string `json:"failed"`
+}
+
+// warmHandler loads a JSON array of keys into the cache using at most
+// concurrency parallel gets.
+func warmHandler(group *groupcache.Group, concurrency int) http.HandlerFunc {
+	return keysHandler(concurrency, func(req *http.Request, key string) error {
+		var value []byte
+		return group.Get(req.Context(), key, groupcache.AllocatingByteSliceSink(&value))
+	})
+}
+
+// keysHandler calls fn for each key of a POSTed JSON array of keys, using at
+// most concurrency parallel calls, and responds with the failed keys.
+func keysHandler(concurrency int, fn func(req *http.Request, key string) error) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var keys []string
+		if err := json.NewDecoder(req.Body).Decode(&keys); err != nil {
+			http.Error(rw, "expected a JSON array of keys: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result := warmResult{Failed: map[string]string{}}
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		queue := make(chan string)
+		for i := 0; i < concurrency; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for key := range queue {
+					err := fn(req, key)
+					mu.Lock()
+					if err != nil {
+						result.Failed[key] = err.Error()
+					} else {
+						result.Succeeded++
+					}
+					mu.Unlock()
+				}
+			}()
+		}
+		for _, key := range keys {
+			queue <- key
+		}
+		close(queue)
+		wg.Wait()
+
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(result)
+	}
+}